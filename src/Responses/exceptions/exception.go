@@ -1,7 +1,10 @@
 package exceptions
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,6 +27,15 @@ func HandleConflictException(context *gin.Context, errText string) {
 	})
 }
 
+func HandleForbiddenException(context *gin.Context, errText string) {
+	context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"statusText": "failed",
+		"statusCode": 403,
+		"errorType":  "ForbiddenException",
+		"error":      errText,
+	})
+}
+
 func HandleInternalServerException(context *gin.Context) {
 	context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 		"statusText": "failed",
@@ -42,6 +54,17 @@ func HandleNotFoundException(context *gin.Context, err error) {
 	})
 }
 
+// HandleTooManyRequestsException tells the client to wait, Retry-After holds the wait in whole seconds
+func HandleTooManyRequestsException(context *gin.Context, errText string, retryAfter time.Duration) {
+	context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	context.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"statusText": "failed",
+		"statusCode": 429,
+		"errorType":  "TooManyRequestsException",
+		"error":      errText,
+	})
+}
+
 func HandleUnauthorizedException(context *gin.Context, errText string) {
 	context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 		"statusText": "failed",
@@ -59,3 +82,14 @@ func HandleValidationException(context *gin.Context, err error) {
 		"error":      err.Error(),
 	})
 }
+
+// HandleFieldValidationException responds with the validation errors of each field of the request
+func HandleFieldValidationException(context *gin.Context, err error, fieldErrors map[string][]string) {
+	context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		"statusText": "failure",
+		"statusCode": 400,
+		"errorType":  "ValidationException",
+		"error":      err.Error(),
+		"fields":     fieldErrors,
+	})
+}
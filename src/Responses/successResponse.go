@@ -2,8 +2,10 @@ package Responses
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
 )
 
 func HandleOkResponse(context *gin.Context, message string, data interface{}) {
@@ -15,6 +17,34 @@ func HandleOkResponse(context *gin.Context, message string, data interface{}) {
 	})
 }
 
+// HandleOkPaginatedResponse adds the pagination details and next/prev links of a listing to the ok response
+func HandleOkPaginatedResponse(context *gin.Context, message string, data interface{}, pagination *dtos.PaginationDto) {
+
+	if pagination.Page == 0 {
+		if pagination.NextCursor != "" {
+			pagination.Next = pageLink(context, "cursor", pagination.NextCursor)
+		}
+		if pagination.PrevCursor != "" {
+			pagination.Prev = pageLink(context, "cursor", pagination.PrevCursor)
+		}
+	} else {
+		if pagination.Page < pagination.TotalPages {
+			pagination.Next = pageLink(context, "page", strconv.Itoa(pagination.Page+1))
+		}
+		if pagination.Page > 1 {
+			pagination.Prev = pageLink(context, "page", strconv.Itoa(pagination.Page-1))
+		}
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"statusText": "success",
+		"statusCode": 200,
+		"message":    message,
+		"data":       data,
+		"pagination": pagination,
+	})
+}
+
 func HandleCreatedResponse(context *gin.Context, message string, data interface{}) {
 	context.JSON(http.StatusCreated, gin.H{
 		"statusText": "success",
@@ -23,3 +53,13 @@ func HandleCreatedResponse(context *gin.Context, message string, data interface{
 		"data":       data,
 	})
 }
+
+// pageLink returns the current request path with the page or cursor query param replaced
+func pageLink(context *gin.Context, param string, value string) string {
+	query := context.Request.URL.Query()
+	query.Del("page")
+	query.Del("cursor")
+	query.Set(param, value)
+
+	return context.Request.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,55 @@
+// Command breached turns a list of plain text passwords, one per line on stdin, into the sorted
+// SHA-1 file PASSWORD_BREACHED_FILE expects, e.g. go run src/breached/build.go < common.txt > breached.txt
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	hashes := map[string]bool{}
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for scanner.Scan() {
+		password := strings.TrimRight(scanner.Text(), "\r")
+
+		if password == "" {
+			continue
+		}
+
+		sum := sha1.Sum([]byte(password))
+		hashes[strings.ToUpper(hex.EncodeToString(sum[:]))] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal("Failed to read passwords: ", err)
+	}
+
+	sorted := make([]string, 0, len(hashes))
+
+	for hash := range hashes {
+		sorted = append(sorted, hash)
+	}
+
+	sort.Strings(sorted)
+
+	writer := bufio.NewWriter(os.Stdout)
+
+	for _, hash := range sorted {
+		fmt.Fprintln(writer, hash)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatal("Failed to write hashes: ", err)
+	}
+
+	log.Printf("Wrote %d hashes", len(sorted))
+}
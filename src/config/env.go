@@ -0,0 +1,46 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvInt returns the integer value of an environment variable, or the fallback when it is not set or invalid
+func GetEnvInt(name string, fallback int) int {
+
+	value := os.Getenv(name)
+
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+
+	if err != nil {
+		log.Printf("Invalid value for %s, using %d: %v", name, fallback, err)
+		return fallback
+	}
+
+	return parsed
+}
+
+// GetEnvDuration returns the duration (e.g. "15m") of an environment variable, or the fallback when it is not set or invalid
+func GetEnvDuration(name string, fallback time.Duration) time.Duration {
+
+	value := os.Getenv(name)
+
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+
+	if err != nil {
+		log.Printf("Invalid value for %s, using %s: %v", name, fallback, err)
+		return fallback
+	}
+
+	return parsed
+}
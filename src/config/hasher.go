@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/hasher"
+)
+
+var PasswordHasher hasher.Hasher
+
+func SetupPasswordHasher() {
+
+	var err error
+	PasswordHasher, err = hasher.NewFromEnv()
+
+	if err != nil {
+		log.Fatal("Failed to set up password hasher: ", err)
+	}
+}
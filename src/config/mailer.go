@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/mailer"
+)
+
+var Mailer mailer.Mailer
+
+func SetupMailer() {
+
+	var err error
+	Mailer, err = mailer.NewFromEnv()
+
+	if err != nil {
+		log.Fatal("Failed to set up mailer: ", err)
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/passwordpolicy"
+)
+
+var PasswordPolicy *passwordpolicy.Policy
+
+func SetupPasswordPolicy() {
+
+	var err error
+	PasswordPolicy, err = passwordpolicy.NewFromEnv()
+
+	if err != nil {
+		log.Fatal("Failed to set up password policy: ", err)
+	}
+}
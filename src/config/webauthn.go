@@ -0,0 +1,19 @@
+package config
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/webauthn"
+)
+
+var RelyingParty *webauthn.RelyingParty
+
+func SetupWebauthn() {
+
+	var err error
+	RelyingParty, err = webauthn.NewFromEnv()
+
+	if err != nil {
+		log.Fatal("Failed to set up WebAuthn: ", err)
+	}
+}
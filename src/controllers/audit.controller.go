@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetAuditLogs godoc
+// @Summary      returns the audit log
+// @Description  security events like logins, lockouts and unlocks, newest first
+// @Tags         Audit
+// @Security 	JWT
+// @Produce      json
+// @Param        event   query     string  false  "Event, e.g. login.failed"
+// @Param        userId  query     string  false  "User ID(UUID)"
+// @Param        email   query     string  false  "Email"
+// @Param        ip      query     string  false  "IP address"
+// @Param        page    query     int     false  "Page number, starting at 1"
+// @Param        limit   query     int     false  "Entries per page (max 100)"
+// @Success      200  {object}  dtos.PaginatedResponseDto{data=[]dtos.AuditLogDto}	"audit log returned"
+// @Failure      400  {object}  dtos.FailedResponseDto	"query validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller is not an admin"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /audit-logs [get]
+func GetAuditLogs(context *gin.Context) {
+
+	//validate query params
+	query := dtos.AuditLogQueryDto{}
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	entries, pagination, err := services.GetAuditLogs(query)
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkPaginatedResponse(context, "Audit Log", entries, pagination)
+}
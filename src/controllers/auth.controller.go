@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jaimy-monsuur/movie-api/src/Responses"
 	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
@@ -10,15 +13,18 @@ import (
 
 // LoginUser godoc
 // @Summary      login user with valid email and password combination
-// @Description  login user
+// @Description  login user, users with two factor authentication and admins get an MFA challenge instead of tokens
 // @Tags         Auth
 // @Security  BasicAuth
 // @Accept       json
 // @Produce      json
 // @Param 		 data	body	dtos.LoginUserDto	true	"User Login Credentials JSON"
-// @Success      200  {object}  dtos.SuccessResponseDto	"login successful"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"login successful"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.MfaChallengeDto}	"password correct, second factor required"
 // @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
 // @Failure      401  {object}  dtos.FailedResponseDto	"invalid credentials"
+// @Failure      403  {object}  dtos.FailedResponseDto	"account is disabled or has to reset its password"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many failed login attempts, wait for Retry-After seconds"
 // @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
 // @Router       /auth/login [post]
 func LoginUser(context *gin.Context) {
@@ -31,27 +37,384 @@ func LoginUser(context *gin.Context) {
 		return
 	}
 
+	ip := context.ClientIP()
+
+	attempt, retryAfter, err := services.CheckLoginAllowed(body.Email, ip)
+	if err != nil {
+
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	if retryAfter > 0 {
+
+		exceptions.HandleTooManyRequestsException(context, "Too many failed login attempts, try again later", retryAfter)
+		return
+	}
+
 	userExists, err := services.GetUserByEmail(body.Email)
 
 	if err != nil {
 
+		services.RecordFailedLogin(attempt, nil)
 		exceptions.HandleUnauthorizedException(context, "Invalid Credentials")
 		return
 	}
 
 	if invalidPasswordError := userExists.ValidatePassword(body.Password); invalidPasswordError != nil {
 
+		services.RecordFailedLogin(attempt, &userExists.ID)
 		exceptions.HandleUnauthorizedException(context, "Invalid Credentials")
 		return
 
 	}
 
-	accessToken, err := services.GenerateJwt(userExists.ID)
+	// the password was right, the attempt was not a failed guess
+	services.ReleaseLoginAttempt(attempt)
+
+	if serviceError := services.CheckPasswordLogin(userExists); serviceError != nil {
+
+		exceptions.HandleForbiddenException(context, serviceError.Error.Error())
+		return
+	}
+
+	// the login is only successful once the second factor is checked
+	if services.MfaRequired(userExists) {
+
+		challenge, err := services.StartMfaChallenge(userExists)
+		if err != nil {
+
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+
+		Responses.HandleOkResponse(context, "MFA Required", challenge)
+		return
+	}
+
+	services.RecordSuccessfulLogin(userExists, ip)
+
+	tokens, err := services.IssueTokens(context, userExists)
+	if err != nil {
+
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Login Successful", tokens)
+}
+
+// RefreshToken godoc
+// @Summary      exchange a refresh token for new tokens
+// @Description  refresh tokens, every refresh token can be used once. Reusing one revokes all tokens of that login
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.RefreshTokenDto	true	"Refresh Token JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"tokens refreshed"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid, expired or reused refresh token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/refresh [post]
+func RefreshToken(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.RefreshTokenDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	tokens, err := services.RefreshTokens(context, body.RefreshToken)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Tokens Refreshed", tokens)
+}
+
+// Logout godoc
+// @Summary      logout
+// @Description  revoke the access token of the request and the refresh token of the same login
+// @Tags         Auth
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.LogoutDto	false	"Refresh Token JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"logout successful"
+// @Failure      400  {object}  dtos.FailedResponseDto	"token not passed with request or personal access token used"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/logout [post]
+func Logout(context *gin.Context) {
+
+	// the body is optional, without it only the access token is revoked
+	body := dtos.LogoutDto{}
+
+	if context.Request.ContentLength > 0 {
+		if err := context.BindJSON(&body); err != nil {
+			exceptions.HandleValidationException(context, err)
+			return
+		}
+	}
+
+	principal, err := services.GetPrincipal(context)
+	if err != nil {
+		exceptions.HandleUnauthorizedException(context, "Unauthorized")
+		return
+	}
+
+	// personal access tokens and app tokens are not a login, they are revoked on their own
+	if principal.Delegated() {
+		exceptions.HandleBadRequestException(context, errors.New("personal access tokens and app tokens cannot log out, revoke the token instead"))
+		return
+	}
+
+	if err := services.Logout(principal, body.RefreshToken); err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Logout Successful", nil)
+}
+
+// LogoutAll godoc
+// @Summary      logout everywhere
+// @Description  revoke every access and refresh token of the logged in user
+// @Tags         Auth
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto	"logout successful"
+// @Failure      400  {object}  dtos.FailedResponseDto	"token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/logout-all [post]
+func LogoutAll(context *gin.Context) {
+
+	principal, err := services.GetPrincipal(context)
+	if err != nil {
+		exceptions.HandleUnauthorizedException(context, "Unauthorized")
+		return
+	}
+
+	if err := services.LogoutAll(principal.UserID); err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Logout Successful", nil)
+}
+
+// VerifyEmail godoc
+// @Summary      verify the email address of a user
+// @Description  verify email with the token from the verification email, refresh the tokens afterwards to get a verified token
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.VerifyEmailDto	true	"Verification Token JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"email verified"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or invalid/expired token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/verify-email [post]
+func VerifyEmail(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.VerifyEmailDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.VerifyEmail(body.Token); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Email Verified", nil)
+}
+
+// ResendVerificationEmail godoc
+// @Summary      resend the verification email
+// @Description  send a new verification email when the email belongs to an unverified user, the response is the same either way
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.ResendVerificationDto	true	"Email JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"verification email sent if needed"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Router       /auth/resend-verification [post]
+func ResendVerificationEmail(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.ResendVerificationDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	services.ResendVerificationEmail(body.Email)
+
+	Responses.HandleOkResponse(context, "If the email belongs to an unverified account a verification email was sent", nil)
+}
+
+// ForgotPassword godoc
+// @Summary      request a password reset email
+// @Description  send a password reset link when the email belongs to a user, the response is the same either way
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.ForgotPasswordDto	true	"Email JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"reset email sent if the user exists"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Router       /auth/forgot-password [post]
+func ForgotPassword(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.ForgotPasswordDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	services.RequestPasswordReset(body.Email)
+
+	Responses.HandleOkResponse(context, "If the email belongs to an account a password reset email was sent", nil)
+}
+
+// ResetPassword godoc
+// @Summary      reset a password
+// @Description  set a new password with the token from the reset email, all tokens of the user are revoked
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.ResetPasswordDto	true	"Reset Token and New Password JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"password reset"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors, password rejected by the password policy or invalid/expired token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/reset-password [post]
+func ResetPassword(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.ResetPasswordDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.ResetPassword(&body); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			if err.FieldErrors != nil {
+				exceptions.HandleFieldValidationException(context, err.Error, err.FieldErrors)
+				return
+			}
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Password Reset", nil)
+}
+
+// GetLockouts godoc
+// @Summary      returns the current login lockouts
+// @Description  accounts and IP addresses that cannot log in because of too many failed attempts
+// @Tags         Auth
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.LockoutDto}	"lockouts returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller is not an admin"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/lockouts [get]
+func GetLockouts(context *gin.Context) {
+
+	lockouts, err := services.GetLockouts()
 	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Current Lockouts", lockouts)
+}
 
+// ClearLockout godoc
+// @Summary      clears a login lockout
+// @Description  unlock an account or IP address and forget its failed login attempts
+// @Tags         Auth
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "Lockout ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"lockout cleared"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller is not an admin"
+// @Failure      404  {object}  dtos.FailedResponseDto	"lockout with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/lockouts/{id} [delete]
+func ClearLockout(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.ClearLockout(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Lockout Cleared", nil)
+}
+
+// GetJwks godoc
+// @Summary      public keys to verify tokens
+// @Description  JSON Web Key Set with the public keys of every signing key that may still be in use
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  dtos.JwksDto	"key set returned"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /.well-known/jwks.json [get]
+func GetJwks(context *gin.Context) {
+
+	jwks, err := services.GetJwks()
+	if err != nil {
 		exceptions.HandleInternalServerException(context)
 		return
 	}
 
-	Responses.HandleOkResponse(context, "Login Successful", accessToken)
+	// verifiers may cache the key set, the next key is published well before it is used
+	context.Header("Cache-Control", "public, max-age=300")
+	context.JSON(http.StatusOK, jwks)
 }
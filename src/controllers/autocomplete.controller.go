@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// Autocomplete godoc
+// @Summary Autocomplete movie titles and people
+// @Description Typo and accent tolerant suggestions for movie titles, directors and actors
+// @Tags Autocomplete
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param q query string true "Text typed so far (at least 2 characters)"
+// @Param limit query int false "Maximum number of suggestions (max 25)"
+// @Success 200 {object} dtos.SuccessResponseDto{data=[]dtos.SuggestionDto} "suggestions returned"
+// @Failure 400 {object} dtos.FailedResponseDto "query param validation error"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /autocomplete [get]
+func Autocomplete(context *gin.Context) {
+	//validate query params
+	query := dtos.AutocompleteQueryDto{}
+
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	suggestions, err := services.Autocomplete(query)
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Suggestions returned", suggestions)
+}
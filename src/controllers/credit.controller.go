@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetMovieCredits godoc
+// @Summary Get the credits of a movie
+// @Description Get the cast and crew of a movie, cast ordered by billing
+// @Tags Credit
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Movie ID"
+// @Success 200 {object} dtos.SuccessResponseDto{data=dtos.MovieCreditsDto} "credits returned"
+// @Failure 404 {object} dtos.FailedResponseDto "movie not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/{id}/credits [get]
+func GetMovieCredits(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	credits, err := services.GetMovieCredits(params.ID)
+
+	if err != nil {
+		exceptions.HandleNotFoundException(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Credits returned", credits)
+}
+
+// CreateCredit godoc
+// @Summary Add a credit to a movie
+// @Description Add a cast or crew credit to a movie, the person is created when only a name is supplied
+// @Tags Credit
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Movie ID"
+// @Param data body dtos.CreateCreditDto true "New Credit Details JSON"
+// @Success 201 {object} dtos.SuccessResponseDto{data=dtos.CreditDto} "credit created successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 404 {object} dtos.FailedResponseDto "movie or person not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/{id}/credits [post]
+func CreateCredit(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	//validate request body
+	body := dtos.CreateCreditDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	credit, err := services.CreateCredit(params.ID, body)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		}
+	}
+
+	Responses.HandleCreatedResponse(context, "Credit Created", credit)
+}
+
+// DeleteCredit godoc
+// @Summary Remove a credit from a movie
+// @Description Remove a credit from a movie
+// @Tags Credit
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Movie ID"
+// @Param creditId path string true "Credit ID"
+// @Success 200 {object} dtos.SuccessResponseDto "credit deleted successfully"
+// @Failure 404 {object} dtos.FailedResponseDto "credit not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/{id}/credits/{creditId} [delete]
+func DeleteCredit(context *gin.Context) {
+	//validate Request Params
+	params := dtos.MovieCreditID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	err := services.DeleteCredit(params.ID, params.CreditID)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Credit Deleted", nil)
+}
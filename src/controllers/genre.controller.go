@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetAllGenres godoc
+// @Summary Get all genres
+// @Description Get all genres
+// @Tags Genre
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Success 200 {object} dtos.SuccessResponseDto{data=[]dtos.GenreDto} "all genres returned"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /genres [get]
+func GetAllGenres(context *gin.Context) {
+	genres, err := services.GetAllGenres()
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Genres returned", genres)
+}
+
+// CreateGenre godoc
+// @Summary Create a genre
+// @Description Create a genre
+// @Tags Genre
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param data body dtos.CreateGenreDto true "New Genre Details JSON"
+// @Success 201 {object} dtos.SuccessResponseDto{data=dtos.GenreDto} "genre created successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 409 {object} dtos.FailedResponseDto "genre with supplied name already exists"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /genres [post]
+func CreateGenre(context *gin.Context) {
+	//validate request body
+	body := dtos.CreateGenreDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	genre, err := services.CreateGenre(body)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 409:
+			exceptions.HandleConflictException(context, err.Error.Error())
+			return
+		}
+	}
+
+	Responses.HandleCreatedResponse(context, "Genre Created", genre)
+}
+
+// UpdateGenre godoc
+// @Summary Update a genre
+// @Description Rename a genre
+// @Tags Genre
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Genre ID"
+// @Param data body dtos.UpdateGenreDto true "Update Genre Details JSON"
+// @Success 200 {object} dtos.SuccessResponseDto{data=dtos.GenreDto} "genre updated successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 404 {object} dtos.FailedResponseDto "genre not found"
+// @Failure 409 {object} dtos.FailedResponseDto "genre with supplied name already exists"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /genres/{id} [put]
+func UpdateGenre(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	//validate request body
+	body := dtos.UpdateGenreDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	genre, err := services.UpdateGenre(params.ID, body)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		case 409:
+			exceptions.HandleConflictException(context, err.Error.Error())
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Genre Updated", genre)
+}
+
+// DeleteGenre godoc
+// @Summary Delete a genre
+// @Description Delete a genre, movies keep their other genres
+// @Tags Genre
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Genre ID"
+// @Success 200 {object} dtos.SuccessResponseDto "genre deleted successfully"
+// @Failure 404 {object} dtos.FailedResponseDto "genre not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /genres/{id} [delete]
+func DeleteGenre(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	err := services.DeleteGenre(params.ID)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Genre Deleted", nil)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetAllKeywords godoc
+// @Summary Get all keywords
+// @Description Get all keywords
+// @Tags Keyword
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Success 200 {object} dtos.SuccessResponseDto{data=[]dtos.KeywordDto} "all keywords returned"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /keywords [get]
+func GetAllKeywords(context *gin.Context) {
+	keywords, err := services.GetAllKeywords()
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Keywords returned", keywords)
+}
+
+// CreateKeyword godoc
+// @Summary Create a keyword
+// @Description Create a keyword, keywords are stored lower case
+// @Tags Keyword
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param data body dtos.CreateKeywordDto true "New Keyword Details JSON"
+// @Success 201 {object} dtos.SuccessResponseDto{data=dtos.KeywordDto} "keyword created successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 409 {object} dtos.FailedResponseDto "keyword already exists"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /keywords [post]
+func CreateKeyword(context *gin.Context) {
+	//validate request body
+	body := dtos.CreateKeywordDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	keyword, err := services.CreateKeyword(body)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 409:
+			exceptions.HandleConflictException(context, err.Error.Error())
+			return
+		}
+	}
+
+	Responses.HandleCreatedResponse(context, "Keyword Created", keyword)
+}
+
+// DeleteKeyword godoc
+// @Summary Delete a keyword
+// @Description Delete a keyword and remove it from every movie
+// @Tags Keyword
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Keyword ID"
+// @Success 200 {object} dtos.SuccessResponseDto "keyword deleted successfully"
+// @Failure 404 {object} dtos.FailedResponseDto "keyword not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /keywords/{id} [delete]
+func DeleteKeyword(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	err := services.DeleteKeyword(params.ID)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Keyword Deleted", nil)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// with device binding the browser that asked for a login link keeps a secret in this cookie, the link only works there
+const magicLinkDeviceCookie = "magic_link_device"
+
+// RequestMagicLink godoc
+// @Summary      request a login link
+// @Description  mails a single use login link when the email belongs to a user, the response is the same either way. With device binding the link only works in the browser that asked for it
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.MagicLinkDto	true	"Email JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"login link sent if the user exists"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many login links requested from this IP address, wait for Retry-After seconds"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/magic-link [post]
+func RequestMagicLink(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.MagicLinkDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	deviceSecret, retryAfter, err := services.RequestMagicLink(body.Email, context.ClientIP())
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	if retryAfter > 0 {
+		exceptions.HandleTooManyRequestsException(context, "Too many login links requested, try again later", retryAfter)
+		return
+	}
+
+	if deviceSecret != "" {
+		context.SetCookie(magicLinkDeviceCookie, deviceSecret, 0, "/auth/magic-link", "", isSecureRequest(context), true)
+	}
+
+	Responses.HandleOkResponse(context, "If the email belongs to an account a login link was sent", nil)
+}
+
+// MagicLinkCallback godoc
+// @Summary      login with a login link
+// @Description  the link from the login email points here, it works once. Users that need a second factor get an MFA challenge instead of tokens
+// @Tags         Auth
+// @Produce      json
+// @Param        token      query     string  true  "Token from the login link"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"login successful"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.MfaChallengeDto}	"second factor required"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request query validation errors"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid, expired or used link, or opened on another device or network"
+// @Failure      403  {object}  dtos.FailedResponseDto	"account is disabled"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/magic-link/callback [get]
+func MagicLinkCallback(context *gin.Context) {
+
+	//validate query params
+	query := dtos.MagicLinkCallbackQueryDto{}
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	deviceSecret, _ := context.Cookie(magicLinkDeviceCookie)
+	ip := context.ClientIP()
+
+	user, serviceError := services.CompleteMagicLinkLogin(query.Token, ip, deviceSecret)
+
+	if serviceError != nil {
+		switch statusCode := serviceError.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, serviceError.Error.Error())
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, serviceError.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	context.SetCookie(magicLinkDeviceCookie, "", -1, "/auth/magic-link", "", isSecureRequest(context), true)
+
+	// the link only proves access to the inbox, so a second factor is asked for like after a password login
+	if services.MfaRequired(user) {
+
+		challenge, err := services.StartMfaChallenge(user)
+		if err != nil {
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+
+		Responses.HandleOkResponse(context, "MFA Required", challenge)
+		return
+	}
+
+	services.RecordSuccessfulLogin(user, ip)
+
+	tokens, err := services.IssueTokens(context, user)
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Login Successful", tokens)
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// VerifyMfa godoc
+// @Summary      finish a login with a second factor
+// @Description  exchange the MFA challenge token from the login and a TOTP or recovery code for tokens. Users that have to enroll confirm their enrollment with a TOTP code and get their recovery codes with the tokens
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.MfaVerifyDto	true	"Challenge Token and Code JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"login successful"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or enrollment not started"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired challenge token or invalid code"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many failed login attempts, wait for Retry-After seconds"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/mfa/verify [post]
+func VerifyMfa(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.MfaVerifyDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	user, serviceError := services.GetMfaChallengeUser(body.ChallengeToken)
+	if serviceError != nil {
+		exceptions.HandleUnauthorizedException(context, "Invalid or expired challenge")
+		return
+	}
+
+	ip := context.ClientIP()
+
+	attempt, retryAfter, err := services.CheckLoginAllowed(user.Email, ip)
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	if retryAfter > 0 {
+		exceptions.HandleTooManyRequestsException(context, "Too many failed login attempts, try again later", retryAfter)
+		return
+	}
+
+	tokens, serviceError := services.CompleteMfaChallenge(context, user, &body, attempt)
+
+	if serviceError != nil {
+		switch statusCode := serviceError.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, serviceError.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, serviceError.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Login Successful", tokens)
+}
+
+// EnrollMfaWithChallenge godoc
+// @Summary      start a forced two factor enrollment during login
+// @Description  users that must use two factor authentication but did not enroll yet get their TOTP secret with the MFA challenge token, the login is finished at /auth/mfa/verify
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.MfaChallengeTokenDto	true	"Challenge Token JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TotpEnrollmentDto}	"enrollment started"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired challenge token"
+// @Failure      409  {object}  dtos.FailedResponseDto	"two factor authentication already enabled"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/mfa/enroll [post]
+func EnrollMfaWithChallenge(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.MfaChallengeTokenDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	enrollment, err := services.StartTotpEnrollmentWithChallenge(body.ChallengeToken)
+
+	if err != nil {
+		handleMfaServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Enrollment Started", enrollment)
+}
+
+// EnrollTotp godoc
+// @Summary      start a two factor enrollment
+// @Description  creates a TOTP secret with otpauth URI and QR code, two factor authentication is turned on once confirmed with a code
+// @Tags         MFA
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TotpEnrollmentDto}	"enrollment started"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      409  {object}  dtos.FailedResponseDto	"two factor authentication already enabled"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/mfa/totp [post]
+func EnrollTotp(context *gin.Context) {
+
+	enrollment, err := services.StartTotpEnrollment(context)
+
+	if err != nil {
+		handleMfaServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Enrollment Started", enrollment)
+}
+
+// ConfirmTotp godoc
+// @Summary      confirm a two factor enrollment
+// @Description  turns on two factor authentication with a code from the authenticator, returns the recovery codes once
+// @Tags         MFA
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.TotpCodeDto	true	"TOTP Code JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.RecoveryCodesDto}	"two factor authentication enabled"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or enrollment not started"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"invalid code or called with a personal access token or app token"
+// @Failure      409  {object}  dtos.FailedResponseDto	"two factor authentication already enabled"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many failed login attempts, wait for Retry-After seconds"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/mfa/totp/confirm [post]
+func ConfirmTotp(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.TotpCodeDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	attempt, retryAfter, serviceError := services.CheckMfaCodeAllowed(context)
+	if serviceError != nil {
+		handleMfaServiceError(context, serviceError)
+		return
+	}
+
+	if retryAfter > 0 {
+		exceptions.HandleTooManyRequestsException(context, "Too many failed login attempts, try again later", retryAfter)
+		return
+	}
+
+	recoveryCodes, err := services.ConfirmTotpEnrollment(context, &body, attempt)
+
+	if err != nil {
+		handleMfaServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Two Factor Authentication Enabled", recoveryCodes)
+}
+
+// DisableTotp godoc
+// @Summary      turn off two factor authentication
+// @Description  requires a TOTP or recovery code, admins cannot turn it off
+// @Tags         MFA
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.TotpCodeDto	true	"TOTP or Recovery Code JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto	"two factor authentication disabled"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or not enabled"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"invalid code, caller is an admin or called with a personal access token or app token"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many failed login attempts, wait for Retry-After seconds"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/mfa/totp [delete]
+func DisableTotp(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.TotpCodeDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	attempt, retryAfter, serviceError := services.CheckMfaCodeAllowed(context)
+	if serviceError != nil {
+		handleMfaServiceError(context, serviceError)
+		return
+	}
+
+	if retryAfter > 0 {
+		exceptions.HandleTooManyRequestsException(context, "Too many failed login attempts, try again later", retryAfter)
+		return
+	}
+
+	if serviceError := services.DisableTotp(context, &body, attempt); serviceError != nil {
+		handleMfaServiceError(context, serviceError)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Two Factor Authentication Disabled", nil)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      replace the recovery codes
+// @Description  requires a TOTP code, all earlier recovery codes stop working
+// @Tags         MFA
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.TotpCodeDto	true	"TOTP Code JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.RecoveryCodesDto}	"new recovery codes"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or not enabled"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"invalid code or called with a personal access token or app token"
+// @Failure      429  {object}  dtos.FailedResponseDto	"too many failed login attempts, wait for Retry-After seconds"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.TotpCodeDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	attempt, retryAfter, serviceError := services.CheckMfaCodeAllowed(context)
+	if serviceError != nil {
+		handleMfaServiceError(context, serviceError)
+		return
+	}
+
+	if retryAfter > 0 {
+		exceptions.HandleTooManyRequestsException(context, "Too many failed login attempts, try again later", retryAfter)
+		return
+	}
+
+	recoveryCodes, err := services.RegenerateRecoveryCodes(context, &body, attempt)
+
+	if err != nil {
+		handleMfaServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Recovery Codes Replaced", recoveryCodes)
+}
+
+func handleMfaServiceError(context *gin.Context, err *interfaces.ServiceError) {
+	switch statusCode := err.StatusCode; statusCode {
+	case 400:
+		exceptions.HandleBadRequestException(context, err.Error)
+	case 401:
+		exceptions.HandleUnauthorizedException(context, err.Error.Error())
+	case 403:
+		exceptions.HandleForbiddenException(context, err.Error.Error())
+	case 404:
+		exceptions.HandleNotFoundException(context, err.Error)
+	case 409:
+		exceptions.HandleConflictException(context, err.Error.Error())
+	default:
+		exceptions.HandleInternalServerException(context)
+	}
+}
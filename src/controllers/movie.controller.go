@@ -49,23 +49,136 @@ func CreateMovie(context *gin.Context) {
 
 // GetAllMovies godoc
 // @Summary Get all movies
-// @Description Get all movies
+// @Description Get a page of movies, sorted and filtered by the supplied query params
 // @Tags Movie
 // @Security JWT
 // @Accept json
 // @Produce json
-// @Success 200 {object} dtos.SuccessResponseDto{data=[]models.Movie} "all movies returned"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Movies per page (max 100)"
+// @Param cursor query string false "Cursor of the next or previous page, overrides page. Pass an empty cursor to start paging by cursor"
+// @Param sort query string false "Sort column" Enums(title, year, avg_rating, created_at)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Param year_from query int false "Minimum release year"
+// @Param year_to query int false "Maximum release year"
+// @Param language query string false "Language"
+// @Param director query string false "Director (partial match)"
+// @Param min_rating query number false "Minimum average rating"
+// @Param min_length query int false "Minimum length"
+// @Param max_length query int false "Maximum length"
+// @Param decade query int false "Decade, e.g. 1990"
+// @Param genre query []string false "Genre name, repeat to require several genres" collectionFormat(multi)
+// @Param keyword query []string false "Keyword, repeat to require several keywords" collectionFormat(multi)
+// @Success 200 {object} dtos.PaginatedResponseDto{data=[]models.Movie} "page of movies returned"
+// @Failure 400 {object} dtos.FailedResponseDto "query param validation error or invalid cursor"
 // @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
 // @Router /movies [get]
 func GetAllMovies(context *gin.Context) {
-	movies, err := services.GetAllMovies()
+	//validate query params
+	query := dtos.MovieQueryDto{}
+
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	query.UseCursor = context.Request.URL.Query().Has("cursor")
+
+	movies, pagination, err := services.GetAllMovies(query)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkPaginatedResponse(context, "Movies returned", movies, pagination)
+}
+
+// GetMovieFacets godoc
+// @Summary Get movie facet counts
+// @Description Count the movies matching the filters per genre, decade and language
+// @Tags Movie
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param year_from query int false "Minimum release year"
+// @Param year_to query int false "Maximum release year"
+// @Param language query string false "Language"
+// @Param director query string false "Director (partial match)"
+// @Param min_rating query number false "Minimum average rating"
+// @Param min_length query int false "Minimum length"
+// @Param max_length query int false "Maximum length"
+// @Param decade query int false "Decade, e.g. 1990"
+// @Param genre query []string false "Genre name, repeat to require several genres" collectionFormat(multi)
+// @Param keyword query []string false "Keyword, repeat to require several keywords" collectionFormat(multi)
+// @Success 200 {object} dtos.SuccessResponseDto{data=dtos.MovieFacetsDto} "facet counts returned"
+// @Failure 400 {object} dtos.FailedResponseDto "query param validation error"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/facets [get]
+func GetMovieFacets(context *gin.Context) {
+	//validate query params
+	query := dtos.MovieQueryDto{}
+
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	facets, err := services.GetMovieFacets(query)
 
 	if err != nil {
 		exceptions.HandleInternalServerException(context)
 		return
 	}
 
-	Responses.HandleOkResponse(context, "Movies returned", movies)
+	Responses.HandleOkResponse(context, "Facets returned", facets)
+}
+
+// SearchMovies godoc
+// @Summary Search movies
+// @Description Full text search over the title, plot, director and actors of movies, ranked by relevance
+// @Tags Movie
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query, supports quoted phrases and prefix* terms"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "Results per page (max 100)"
+// @Success 200 {object} dtos.PaginatedResponseDto{data=[]dtos.MovieSearchResultDto} "matching movies returned"
+// @Failure 400 {object} dtos.FailedResponseDto "query param validation error"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/search [get]
+func SearchMovies(context *gin.Context) {
+	//validate query params
+	query := dtos.MovieSearchQueryDto{}
+
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	results, pagination, err := services.SearchMovies(query)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkPaginatedResponse(context, "Search results returned", results, pagination)
 }
 
 // UpdateMovie godoc
@@ -174,3 +287,45 @@ func GetMovieByID(context *gin.Context) {
 
 	Responses.HandleOkResponse(context, "Movie returned", movie)
 }
+
+// RecomputeMovieRating godoc
+// @Summary Recompute the rating of a movie
+// @Description Recalculate the average rating and number of ratings of a movie from its reviews
+// @Tags Movie
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Movie ID"
+// @Success 200 {object} dtos.SuccessResponseDto{data=models.Movie} "movie rating recomputed"
+// @Failure 400 {object} dtos.FailedResponseDto "request param validation error"
+// @Failure 404 {object} dtos.FailedResponseDto "movie not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/{id}/ratings/recompute [post]
+func RecomputeMovieRating(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	movie, err := services.RecomputeMovieRating(params.ID)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Movie Rating Recomputed", movie)
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// Authorize godoc
+// @Summary      start an authorization of an app
+// @Description  the authorization endpoint of the authorization code flow, PKCE with S256 is required. Redirects the browser to the consent screen of the front end, or back to the app with an error
+// @Tags         OAuth2
+// @Produce      json
+// @Param        response_type          query   string  true   "Must be code"
+// @Param        client_id              query   string  true   "Client ID of the app"
+// @Param        redirect_uri           query   string  false  "One of the registered redirect URIs, optional when the app has only one"
+// @Param        scope                  query   string  false  "Space separated scopes, defaults to all scopes of the app"
+// @Param        state                  query   string  false  "Returned to the app unchanged"
+// @Param        code_challenge         query   string  true   "PKCE code challenge"
+// @Param        code_challenge_method  query   string  true   "Must be S256"
+// @Success      302  "redirect to the consent screen or back to the app"
+// @Failure      400  {object}  dtos.OAuthErrorDto	"unknown app or redirect URI"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/authorize [get]
+func Authorize(context *gin.Context) {
+
+	//validate query params
+	query := dtos.AuthorizationRequestDto{}
+	if err := context.ShouldBindQuery(&query); err != nil {
+		handleOAuthError(context, &interfaces.ServiceError{
+			Error:      &services.OAuthError{Code: "invalid_request", Description: err.Error()},
+			StatusCode: 400,
+		})
+		return
+	}
+
+	redirectURL, err := services.StartAuthorization(&query)
+
+	if err != nil {
+		handleOAuthError(context, err)
+		return
+	}
+
+	context.Redirect(http.StatusFound, redirectURL)
+}
+
+// GetConsent godoc
+// @Summary      get the consent screen of an app
+// @Description  the app and the scopes it gets when the logged in user approves, takes the query of the authorization request
+// @Tags         OAuth2
+// @Security 	JWT
+// @Produce      json
+// @Param        client_id              query   string  true   "Client ID of the app"
+// @Param        redirect_uri           query   string  false  "Redirect URI of the authorization request"
+// @Param        scope                  query   string  false  "Space separated scopes"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.ConsentDto}	"consent screen returned"
+// @Failure      400  {object}  dtos.FailedResponseDto	"invalid authorization request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/consent [get]
+func GetConsent(context *gin.Context) {
+
+	//validate query params
+	query := dtos.AuthorizationRequestDto{}
+	if err := context.BindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	consent, err := services.GetConsent(context, &query)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Consent", consent)
+}
+
+// DecideConsent godoc
+// @Summary      approve or decline an app
+// @Description  records the answer of the logged in user, returns where to send the browser: back to the app with an authorization code, or with access_denied when declined
+// @Tags         OAuth2
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.ConsentDecisionDto	true	"Authorization Request and Approved JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.AuthorizationResponseDto}	"redirect URI returned"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or unknown app or redirect URI"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/consent [post]
+func DecideConsent(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.ConsentDecisionDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	response, err := services.DecideConsent(context, &body)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Consent Recorded", response)
+}
+
+// ExchangeOAuthToken godoc
+// @Summary      get tokens for an app
+// @Description  the token endpoint, supports the authorization_code, refresh_token and client_credentials grants. Apps authenticate with HTTP basic authentication or client_id and client_secret in the form, public apps only send their client_id
+// @Tags         OAuth2
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        grant_type     formData  string  true   "authorization_code, refresh_token or client_credentials"
+// @Param        code           formData  string  false  "Authorization code"
+// @Param        redirect_uri   formData  string  false  "Redirect URI of the authorization request, required with authorization_code"
+// @Param        code_verifier  formData  string  false  "PKCE code verifier"
+// @Param        refresh_token  formData  string  false  "Refresh token"
+// @Param        scope          formData  string  false  "Space separated scopes"
+// @Param        client_id      formData  string  false  "Client ID, when not using basic authentication"
+// @Param        client_secret  formData  string  false  "Client secret, when not using basic authentication"
+// @Success      200  {object}  dtos.OAuthTokenDto	"tokens issued"
+// @Failure      400  {object}  dtos.OAuthErrorDto	"invalid request, grant or scope"
+// @Failure      401  {object}  dtos.OAuthErrorDto	"app authentication failed"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/token [post]
+func ExchangeOAuthToken(context *gin.Context) {
+
+	// token responses hold credentials, RFC 6749 section 5.1
+	context.Header("Cache-Control", "no-store")
+	context.Header("Pragma", "no-cache")
+
+	// Validate Request Body
+	body := dtos.TokenRequestDto{}
+
+	if err := context.ShouldBind(&body); err != nil {
+		handleOAuthError(context, &interfaces.ServiceError{
+			Error:      &services.OAuthError{Code: "invalid_request", Description: err.Error()},
+			StatusCode: 400,
+		})
+		return
+	}
+
+	clientID, clientSecret := oauthClientCredentials(context, body.ClientID, body.ClientSecret)
+
+	tokens, err := services.ExchangeOAuthToken(&body, clientID, clientSecret)
+
+	if err != nil {
+		handleOAuthError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, tokens)
+}
+
+// IntrospectOAuthToken godoc
+// @Summary      introspect a token
+// @Description  describes a token issued to the calling confidential app (RFC 7662), tokens of other apps are reported inactive
+// @Tags         OAuth2
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token            formData  string  true   "Access or refresh token"
+// @Param        token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Param        client_id        formData  string  false  "Client ID, when not using basic authentication"
+// @Param        client_secret    formData  string  false  "Client secret, when not using basic authentication"
+// @Success      200  {object}  dtos.IntrospectionDto	"token described"
+// @Failure      400  {object}  dtos.OAuthErrorDto	"invalid request or public app"
+// @Failure      401  {object}  dtos.OAuthErrorDto	"app authentication failed"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/introspect [post]
+func IntrospectOAuthToken(context *gin.Context) {
+
+	context.Header("Cache-Control", "no-store")
+
+	// Validate Request Body
+	body := dtos.TokenOperationDto{}
+
+	if err := context.ShouldBind(&body); err != nil {
+		handleOAuthError(context, &interfaces.ServiceError{
+			Error:      &services.OAuthError{Code: "invalid_request", Description: err.Error()},
+			StatusCode: 400,
+		})
+		return
+	}
+
+	clientID, clientSecret := oauthClientCredentials(context, body.ClientID, body.ClientSecret)
+
+	introspection, err := services.IntrospectOAuthToken(&body, clientID, clientSecret)
+
+	if err != nil {
+		handleOAuthError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, introspection)
+}
+
+// RevokeOAuthToken godoc
+// @Summary      revoke a token
+// @Description  revokes a token issued to the calling app (RFC 7009), revoking a refresh token also revokes its access tokens. Unknown tokens are not an error
+// @Tags         OAuth2
+// @Accept       x-www-form-urlencoded
+// @Param        token            formData  string  true   "Access or refresh token"
+// @Param        token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Param        client_id        formData  string  false  "Client ID, when not using basic authentication"
+// @Param        client_secret    formData  string  false  "Client secret, when not using basic authentication"
+// @Success      200  "token revoked"
+// @Failure      400  {object}  dtos.OAuthErrorDto	"invalid request"
+// @Failure      401  {object}  dtos.OAuthErrorDto	"app authentication failed"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/revoke [post]
+func RevokeOAuthToken(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.TokenOperationDto{}
+
+	if err := context.ShouldBind(&body); err != nil {
+		handleOAuthError(context, &interfaces.ServiceError{
+			Error:      &services.OAuthError{Code: "invalid_request", Description: err.Error()},
+			StatusCode: 400,
+		})
+		return
+	}
+
+	clientID, clientSecret := oauthClientCredentials(context, body.ClientID, body.ClientSecret)
+
+	if err := services.RevokeOAuthToken(&body, clientID, clientSecret); err != nil {
+		handleOAuthError(context, err)
+		return
+	}
+
+	context.Status(http.StatusOK)
+}
+
+// oauthClientCredentials prefers HTTP basic authentication over credentials in the form, the basic
+// credentials are form encoded, RFC 6749 section 2.3.1
+func oauthClientCredentials(context *gin.Context, formClientID string, formClientSecret string) (string, string) {
+
+	clientID, clientSecret, ok := context.Request.BasicAuth()
+
+	if !ok {
+		return formClientID, formClientSecret
+	}
+
+	if unescaped, err := url.QueryUnescape(clientID); err == nil {
+		clientID = unescaped
+	}
+
+	if unescaped, err := url.QueryUnescape(clientSecret); err == nil {
+		clientSecret = unescaped
+	}
+
+	return clientID, clientSecret
+}
+
+// handleOAuthError answers in the error format of the OAuth2 endpoints instead of the usual error response
+func handleOAuthError(context *gin.Context, err *interfaces.ServiceError) {
+
+	var oauthError *services.OAuthError
+
+	if !errors.As(err.Error, &oauthError) {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	if err.StatusCode == http.StatusUnauthorized {
+		context.Header("WWW-Authenticate", `Basic realm="movie-api"`)
+	}
+
+	context.AbortWithStatusJSON(err.StatusCode, dtos.OAuthErrorDto{
+		Error:            oauthError.Code,
+		ErrorDescription: oauthError.Description,
+	})
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// CreateOAuthClient godoc
+// @Summary      register an app
+// @Description  registers a third-party app that acts on behalf of users, limited to scopes the roles of the owner have. Confidential apps get a secret, it is only returned once
+// @Tags         OAuth2
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.CreateOAuthClientDto	true	"App Name, Redirect URIs, Scopes and Confidential JSON"
+// @Success      201  {object}  dtos.SuccessResponseDto{data=dtos.CreatedOAuthClientDto}	"app registered"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors, unknown scope or invalid redirect URI"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"scope not granted to the role or called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/clients [post]
+func CreateOAuthClient(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.CreateOAuthClientDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	client, err := services.CreateOAuthClient(context, &body)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleCreatedResponse(context, "App Registered", client)
+}
+
+// GetOAuthClients godoc
+// @Summary      list apps
+// @Description  the apps registered by the logged in user
+// @Tags         OAuth2
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.OAuthClientDto}	"apps returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/clients [get]
+func GetOAuthClients(context *gin.Context) {
+
+	clients, err := services.GetOAuthClients(context)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Apps", clients)
+}
+
+// DeleteOAuthClient godoc
+// @Summary      delete an app
+// @Description  deletes an app of the logged in user, all tokens issued to it stop working right away
+// @Tags         OAuth2
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "App ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"app deleted"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      404  {object}  dtos.FailedResponseDto	"app with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /oauth/clients/{id} [delete]
+func DeleteOAuthClient(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.DeleteOAuthClient(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "App Deleted", nil)
+}
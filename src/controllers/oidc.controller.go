@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// the state of a started login is also kept in a cookie, so a callback only works in the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// StartOidcLogin godoc
+// @Summary      start a login at an identity provider
+// @Description  redirects the browser to the OpenID Connect provider, using the authorization code flow with PKCE
+// @Tags         Auth
+// @Param        provider   path      string  true  "Provider name, as configured in OIDC_PROVIDERS"
+// @Success      302  "redirect to the identity provider"
+// @Failure      404  {object}  dtos.FailedResponseDto	"unknown provider"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error or provider discovery failed"
+// @Router       /auth/oidc/{provider}/start [get]
+func StartOidcLogin(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.OidcProviderDto{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	authURL, state, err := services.StartOidcLogin(params.Provider)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	context.SetCookie(oidcStateCookie, state, 600, "/auth/oidc/"+params.Provider, "", isSecureRequest(context), true)
+	context.Redirect(http.StatusFound, authURL)
+}
+
+// OidcCallback godoc
+// @Summary      finish a login at an identity provider
+// @Description  the identity provider redirects here, the user is linked by verified email on its first login. Users that need a second factor get an MFA challenge instead of tokens
+// @Tags         Auth
+// @Produce      json
+// @Param        provider   path      string  true  "Provider name"
+// @Param        code       query     string  false  "Authorization code"
+// @Param        state      query     string  true   "State from the start of the login"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"login successful"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.MfaChallengeDto}	"second factor required"
+// @Failure      400  {object}  dtos.FailedResponseDto	"invalid, expired or foreign login state"
+// @Failure      401  {object}  dtos.FailedResponseDto	"login at the identity provider failed"
+// @Failure      403  {object}  dtos.FailedResponseDto	"email not verified by the identity provider"
+// @Failure      404  {object}  dtos.FailedResponseDto	"unknown provider"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/oidc/{provider}/callback [get]
+func OidcCallback(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.OidcProviderDto{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	//validate query params
+	query := dtos.OidcCallbackQueryDto{}
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if query.Error != "" {
+		exceptions.HandleUnauthorizedException(context, "Login at identity provider failed: "+query.Error+" "+query.ErrorDescription)
+		return
+	}
+
+	stateCookie, err := context.Cookie(oidcStateCookie)
+	context.SetCookie(oidcStateCookie, "", -1, "/auth/oidc/"+params.Provider, "", isSecureRequest(context), true)
+
+	if err != nil || query.State == "" || stateCookie != query.State {
+		exceptions.HandleBadRequestException(context, errors.New("login state does not match this browser"))
+		return
+	}
+
+	login, serviceError := services.CompleteOidcLogin(context.Request.Context(), params.Provider, query.Code, query.State)
+
+	if serviceError != nil {
+		switch statusCode := serviceError.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, serviceError.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, serviceError.Error.Error())
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, serviceError.Error.Error())
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, serviceError.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	// a second factor checked by the provider counts, otherwise it is asked for like after a password login
+	if services.MfaRequired(login.User) && !login.MfaDone {
+
+		challenge, err := services.StartMfaChallenge(login.User)
+		if err != nil {
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+
+		Responses.HandleOkResponse(context, "MFA Required", challenge)
+		return
+	}
+
+	services.RecordSuccessfulLogin(login.User, context.ClientIP())
+
+	tokens, err := services.IssueTokens(context, login.User)
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Login Successful", tokens)
+}
+
+func isSecureRequest(context *gin.Context) bool {
+	return context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// StartPasskeyRegistration godoc
+// @Summary      start a passkey registration
+// @Description  returns the options to pass to navigator.credentials.create(), the created credential is sent to /users/me/passkeys within 5 minutes
+// @Tags         Passkeys
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=webauthn.CreationOptions}	"registration started"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/passkeys/options [post]
+func StartPasskeyRegistration(context *gin.Context) {
+
+	options, err := services.StartPasskeyRegistration(context)
+
+	if err != nil {
+		handlePasskeyServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Passkey Registration Started", options)
+}
+
+// RegisterPasskey godoc
+// @Summary      register a passkey
+// @Description  stores the credential navigator.credentials.create() returned, the passkey can be used to log in right away
+// @Tags         Passkeys
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.RegisterPasskeyDto	true	"Passkey Name and Credential JSON"
+// @Success      201  {object}  dtos.SuccessResponseDto{data=dtos.PasskeyDto}	"passkey registered"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or credential verification failed"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token or challenge"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      409  {object}  dtos.FailedResponseDto	"passkey already registered"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/passkeys [post]
+func RegisterPasskey(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.RegisterPasskeyDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	passkey, err := services.FinishPasskeyRegistration(context, &body)
+
+	if err != nil {
+		handlePasskeyServiceError(context, err)
+		return
+	}
+
+	Responses.HandleCreatedResponse(context, "Passkey Registered", passkey)
+}
+
+// GetPasskeys godoc
+// @Summary      list passkeys
+// @Description  all passkeys of the logged in user
+// @Tags         Passkeys
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.PasskeyDto}	"passkeys returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/passkeys [get]
+func GetPasskeys(context *gin.Context) {
+
+	passkeys, err := services.GetPasskeys(context)
+
+	if err != nil {
+		handlePasskeyServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Passkeys", passkeys)
+}
+
+// DeletePasskey godoc
+// @Summary      remove a passkey
+// @Description  the passkey cannot be used to log in anymore
+// @Tags         Passkeys
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "Passkey ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"passkey removed"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      404  {object}  dtos.FailedResponseDto	"passkey with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/passkeys/{id} [delete]
+func DeletePasskey(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.DeletePasskey(context, params.ID); err != nil {
+		handlePasskeyServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Passkey Removed", nil)
+}
+
+// StartPasskeyLogin godoc
+// @Summary      start a passkey login
+// @Description  returns the options to pass to navigator.credentials.get(), with an email only the passkeys of that user are allowed
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.PasskeyLoginOptionsDto	true	"Optional Email JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=webauthn.RequestOptions}	"login started"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/passkey/options [post]
+func StartPasskeyLogin(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.PasskeyLoginOptionsDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	options, err := services.StartPasskeyLogin(&body)
+
+	if err != nil {
+		handlePasskeyServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Passkey Login Started", options)
+}
+
+// FinishPasskeyLogin godoc
+// @Summary      login with a passkey
+// @Description  exchange the credential navigator.credentials.get() returned for tokens. The authenticator verified the user, so no second factor is asked for
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.PasskeyLoginDto	true	"Credential JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.TokenDto}	"login successful"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired challenge, unknown passkey or verification failed"
+// @Failure      403  {object}  dtos.FailedResponseDto	"account is disabled"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /auth/passkey/verify [post]
+func FinishPasskeyLogin(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.PasskeyLoginDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	user, serviceError := services.FinishPasskeyLogin(&body)
+
+	if serviceError != nil {
+		handlePasskeyServiceError(context, serviceError)
+		return
+	}
+
+	services.RecordSuccessfulLogin(user, context.ClientIP())
+
+	tokens, err := services.IssueTokens(context, user)
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Login Successful", tokens)
+}
+
+func handlePasskeyServiceError(context *gin.Context, err *interfaces.ServiceError) {
+	switch statusCode := err.StatusCode; statusCode {
+	case 400:
+		exceptions.HandleBadRequestException(context, err.Error)
+	case 401:
+		exceptions.HandleUnauthorizedException(context, err.Error.Error())
+	case 403:
+		exceptions.HandleForbiddenException(context, err.Error.Error())
+	case 404:
+		exceptions.HandleNotFoundException(context, err.Error)
+	case 409:
+		exceptions.HandleConflictException(context, err.Error.Error())
+	default:
+		exceptions.HandleInternalServerException(context)
+	}
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetAllPeople godoc
+// @Summary Get all people
+// @Description Get a page of people, optionally filtered by name
+// @Tags Person
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param name query string false "Name (partial match)"
+// @Param page query int false "Page number, starting at 1"
+// @Param limit query int false "People per page (max 100)"
+// @Success 200 {object} dtos.PaginatedResponseDto{data=[]dtos.PersonDto} "page of people returned"
+// @Failure 400 {object} dtos.FailedResponseDto "query param validation error"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /people [get]
+func GetAllPeople(context *gin.Context) {
+	//validate query params
+	query := dtos.PeopleQueryDto{}
+
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	people, pagination, err := services.GetAllPeople(query)
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkPaginatedResponse(context, "People returned", people, pagination)
+}
+
+// GetPersonByID godoc
+// @Summary Get a person
+// @Description Get a person
+// @Tags Person
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Person ID"
+// @Success 200 {object} dtos.SuccessResponseDto{data=dtos.PersonDto} "person returned"
+// @Failure 404 {object} dtos.FailedResponseDto "person not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /people/{id} [get]
+func GetPersonByID(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	person, err := services.GetPersonByID(params.ID)
+
+	if err != nil {
+		exceptions.HandleNotFoundException(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Person returned", person)
+}
+
+// GetFilmography godoc
+// @Summary Get the filmography of a person
+// @Description Get every cast and crew credit of a person, newest movies first
+// @Tags Person
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Person ID"
+// @Success 200 {object} dtos.SuccessResponseDto{data=[]dtos.FilmographyEntryDto} "filmography returned"
+// @Failure 404 {object} dtos.FailedResponseDto "person not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /people/{id}/filmography [get]
+func GetFilmography(context *gin.Context) {
+	//validate Request Params
+	params := dtos.EntityID{}
+
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	filmography, err := services.GetFilmography(params.ID)
+
+	if err != nil {
+		exceptions.HandleNotFoundException(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Filmography returned", filmography)
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// CreatePersonalAccessToken godoc
+// @Summary      create a personal access token
+// @Description  creates a token for scripts and integrations, limited to scopes the role of the user has. The token is only returned once, send it as bearer token like an access token
+// @Tags         Personal Access Tokens
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.CreatePersonalAccessTokenDto	true	"Token Name, Scopes and Expiry JSON"
+// @Success      201  {object}  dtos.SuccessResponseDto{data=dtos.CreatedPersonalAccessTokenDto}	"token created"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation errors or unknown scope"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"scope not granted to the role or called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/tokens [post]
+func CreatePersonalAccessToken(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.CreatePersonalAccessTokenDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	token, err := services.CreatePersonalAccessToken(context, &body)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleCreatedResponse(context, "Personal Access Token Created", token)
+}
+
+// GetPersonalAccessTokens godoc
+// @Summary      list personal access tokens
+// @Description  all personal access tokens of the logged in user, including expired and revoked ones
+// @Tags         Personal Access Tokens
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.PersonalAccessTokenDto}	"tokens returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/tokens [get]
+func GetPersonalAccessTokens(context *gin.Context) {
+
+	tokens, err := services.GetPersonalAccessTokens(context)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Personal Access Tokens", tokens)
+}
+
+// RevokePersonalAccessToken godoc
+// @Summary      revoke a personal access token
+// @Description  the token stops working right away
+// @Tags         Personal Access Tokens
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "Token ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"token revoked"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      404  {object}  dtos.FailedResponseDto	"token with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/tokens/{id} [delete]
+func RevokePersonalAccessToken(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.RevokePersonalAccessToken(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Personal Access Token Revoked", nil)
+}
@@ -10,17 +10,24 @@ import (
 
 // CreateReview godoc
 // @Summary Create a review
-// @Description Create a review
+// @Description Create a review. Deprecated, use POST /v2/reviews: the author is taken from the token
 // @Tags Review
+// @Security JWT
 // @Accept json
 // @Produce json
 // @Param data body dtos.CreateReviewDto true "New Review Details JSON"
 // @Success 201 {object} dtos.SuccessResponseDto{data=models.Review} "review created successfully"
 // @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
-// @Failure 409 {object} dtos.FailedResponseDto "review with supplied title already exists"
+// @Failure 401 {object} dtos.FailedResponseDto "userId does not match the token"
+// @Failure 404 {object} dtos.FailedResponseDto "movie not found"
+// @Failure 409 {object} dtos.FailedResponseDto "user already reviewed this movie"
 // @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
 // @Router /reviews [post]
+// @Deprecated
 func CreateReview(context *gin.Context) {
+	context.Header("Deprecation", "true")
+	context.Header("Link", "</v2/reviews>; rel=\"successor-version\"")
+
 	//validate request body
 	body := dtos.CreateReviewDto{}
 
@@ -30,6 +37,50 @@ func CreateReview(context *gin.Context) {
 		return
 	}
 
+	//the author comes from the token, a userId that was still sent has to match it
+	if body.UserID != "" {
+		if err := services.CheckUser(context, body.UserID); err != nil {
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		}
+	}
+
+	createReview(context, dtos.CreateReviewV2Dto{
+		MovieID: body.MovieID,
+		Review:  body.Review,
+		Rating:  body.Rating,
+	})
+}
+
+// CreateReviewV2 godoc
+// @Summary Create a review
+// @Description Create a review of a movie by the logged in user
+// @Tags Review
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param data body dtos.CreateReviewV2Dto true "New Review Details JSON"
+// @Success 201 {object} dtos.SuccessResponseDto{data=models.Review} "review created successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 401 {object} dtos.FailedResponseDto "invalid/expired token"
+// @Failure 404 {object} dtos.FailedResponseDto "movie not found"
+// @Failure 409 {object} dtos.FailedResponseDto "user already reviewed this movie"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /v2/reviews [post]
+func CreateReviewV2(context *gin.Context) {
+	//validate request body
+	body := dtos.CreateReviewV2Dto{}
+
+	if err := context.BindJSON(&body); err != nil {
+
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	createReview(context, body)
+}
+
+func createReview(context *gin.Context, body dtos.CreateReviewV2Dto) {
 	newReview, err := services.CreateReview(context, body)
 
 	if err != nil {
@@ -53,6 +104,64 @@ func CreateReview(context *gin.Context) {
 	Responses.HandleCreatedResponse(context, "Review Created", newReview)
 }
 
+// UpsertMyReview godoc
+// @Summary Create or replace my review of a movie
+// @Description Create the review of the logged in user for a movie, or replace it when it already exists
+// @Tags Review
+// @Security JWT
+// @Accept json
+// @Produce json
+// @Param id path string true "Movie ID"
+// @Param data body dtos.UpsertReviewDto true "Review Details JSON"
+// @Success 200 {object} dtos.SuccessResponseDto{data=models.Review} "review replaced successfully"
+// @Success 201 {object} dtos.SuccessResponseDto{data=models.Review} "review created successfully"
+// @Failure 400 {object} dtos.FailedResponseDto "request body validation error"
+// @Failure 401 {object} dtos.FailedResponseDto "invalid/expired token"
+// @Failure 404 {object} dtos.FailedResponseDto "movie not found"
+// @Failure 500 {object} dtos.FailedResponseDto "unexpected internal server error"
+// @Router /movies/{id}/my-review [put]
+func UpsertMyReview(context *gin.Context) {
+	id := dtos.EntityID{}
+
+	if err := context.ShouldBindUri(&id); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	//validate request body
+	body := dtos.UpsertReviewDto{}
+
+	if err := context.BindJSON(&body); err != nil {
+
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	review, created, err := services.UpsertMyReview(context, id.ID, body)
+
+	if err != nil {
+
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleBadRequestException(context, err.Error)
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		}
+	}
+
+	if created {
+		Responses.HandleCreatedResponse(context, "Review Created", review)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Review Updated", review)
+}
+
 // GetReviewByMovieId godoc
 // @Summary Get a review by movie id
 // @Description Get a review by movie id
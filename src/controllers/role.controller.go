@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetAllRoles godoc
+// @Summary      returns all roles
+// @Description  built-in and custom roles with their permissions
+// @Tags         Role
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.RoleDto}	"all roles returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /roles [get]
+func GetAllRoles(context *gin.Context) {
+
+	roles, err := services.GetAllRoles()
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Roles returned", roles)
+}
+
+// CreateRole godoc
+// @Summary      creates a custom role
+// @Description  create a role with a set of permissions
+// @Tags         Role
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param 		 data	body	dtos.CreateRoleDto	true	"New Role JSON"
+// @Success      201  {object}  dtos.SuccessResponseDto{data=dtos.RoleDto}	"role created successfully"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body validation error or unknown permission"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission"
+// @Failure      409  {object}  dtos.FailedResponseDto	"role with supplied name already exists"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /roles [post]
+func CreateRole(context *gin.Context) {
+
+	// Validate Request Body
+	body := dtos.CreateRoleDto{}
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	role, err := services.CreateRole(&body)
+
+	if err != nil {
+		handleRoleServiceError(context, err)
+		return
+	}
+
+	Responses.HandleCreatedResponse(context, "Role Created", role)
+}
+
+// UpdateRole godoc
+// @Summary      updates a custom role
+// @Description  replace the description and permissions of a custom role, tokens of its users are invalidated
+// @Tags         Role
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Role ID(UUID)"
+// @Param 		 data	body	dtos.UpdateRoleDto	true	"Role JSON"
+// @Success      200  {object}  dtos.SuccessResponseDto{data=dtos.RoleDto}	"role updated successfully"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body/param validation error or unknown permission"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission or the role is built-in"
+// @Failure      404  {object}  dtos.FailedResponseDto	"role with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /roles/{id} [put]
+func UpdateRole(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	// Validate Request Body
+	body := dtos.UpdateRoleDto{}
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	role, err := services.UpdateRole(params.ID, &body)
+
+	if err != nil {
+		handleRoleServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Role Updated", role)
+}
+
+// DeleteRole godoc
+// @Summary      deletes a custom role
+// @Description  delete a custom role, its users lose its permissions right away
+// @Tags         Role
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "Role ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"role deleted successfully"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission or the role is built-in"
+// @Failure      404  {object}  dtos.FailedResponseDto	"role with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /roles/{id} [delete]
+func DeleteRole(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.DeleteRole(params.ID); err != nil {
+		handleRoleServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Role Deleted", nil)
+}
+
+// GetAllPermissions godoc
+// @Summary      returns all permissions
+// @Description  the permissions roles are made of, they are the scopes of tokens
+// @Tags         Role
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.PermissionDto}	"all permissions returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /permissions [get]
+func GetAllPermissions(context *gin.Context) {
+
+	permissions, err := services.GetAllPermissions()
+
+	if err != nil {
+		exceptions.HandleInternalServerException(context)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "Permissions returned", permissions)
+}
+
+func handleRoleServiceError(context *gin.Context, err *interfaces.ServiceError) {
+	switch statusCode := err.StatusCode; statusCode {
+	case 400:
+		exceptions.HandleBadRequestException(context, err.Error)
+	case 403:
+		exceptions.HandleForbiddenException(context, err.Error.Error())
+	case 404:
+		exceptions.HandleNotFoundException(context, err.Error)
+	case 409:
+		exceptions.HandleConflictException(context, err.Error.Error())
+	default:
+		exceptions.HandleInternalServerException(context)
+	}
+}
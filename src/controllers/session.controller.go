@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/Responses"
+	"github.com/jaimy-monsuur/movie-api/src/Responses/exceptions"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+// GetSessions godoc
+// @Summary      list sessions
+// @Description  the devices the logged in user is logged in on, with the IP address and time they were last seen
+// @Tags         Sessions
+// @Security 	JWT
+// @Produce      json
+// @Success      200  {object}  dtos.SuccessResponseDto{data=[]dtos.SessionDto}	"sessions returned"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/sessions [get]
+func GetSessions(context *gin.Context) {
+
+	sessions, err := services.GetSessions(context)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Sessions", sessions)
+}
+
+// RevokeSession godoc
+// @Summary      revoke a session
+// @Description  logs the device out, its access and refresh tokens stop working right away
+// @Tags         Sessions
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "Session ID(UUID)"
+// @Success      200  {object}  dtos.SuccessResponseDto	"session revoked"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
+// @Failure      404  {object}  dtos.FailedResponseDto	"session with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/me/sessions/{id} [delete]
+func RevokeSession(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.RevokeSession(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Session Revoked", nil)
+}
@@ -18,7 +18,7 @@ import (
 // @Produce      json
 // @Param 		 data	body	dtos.CreateUserDto	true	"New User Details JSON"
 // @Success      201  {object}  dtos.SuccessResponseDto{data=models.User}	"user created successfully"
-// @Failure      400  {object}  dtos.FailedResponseDto "request body validation error"
+// @Failure      400  {object}  dtos.FailedResponseDto "request body validation error or password rejected by the password policy"
 // @Failure      409  {object}  dtos.FailedResponseDto "another user with supplied email exists"
 // @Failure      500  {object}  dtos.FailedResponseDto "unexpected internal server error"
 // @Router       /users [post]
@@ -39,6 +39,10 @@ func CreateUser(context *gin.Context) {
 
 		switch statusCode := err.StatusCode; statusCode {
 		case 400:
+			if err.FieldErrors != nil {
+				exceptions.HandleFieldValidationException(context, err.Error, err.FieldErrors)
+				return
+			}
 			exceptions.HandleBadRequestException(context, err.Error)
 			return
 		case 409:
@@ -51,32 +55,47 @@ func CreateUser(context *gin.Context) {
 }
 
 // GetAllUsers godoc
-// @Summary      returns all users
-// @Description  get all users
+// @Summary      returns users
+// @Description  search users by email, name, role and last login, ordered by email
 // @Tags         User
 // @Security 	JWT
 // @Accept       json
 // @Produce      json
-// @success 200 {object} dtos.SuccessResponseDto{data=[]models.User}	"all users returned"
-// @Failure      400  {object}  dtos.FailedResponseDto	"token not passed with request"
+// @Param        email            query     string  false  "Part of the email"
+// @Param        name             query     string  false  "Part of the full name"
+// @Param        role             query     string  false  "Role name, e.g. moderator"
+// @Param        lastLoginAfter   query     string  false  "RFC 3339 time the last login is at or after"
+// @Param        lastLoginBefore  query     string  false  "RFC 3339 time the last login is before"
+// @Param        disabled         query     bool    false  "Only disabled or only enabled users"
+// @Param        page             query     int     false  "Page number, starting at 1"
+// @Param        limit            query     int     false  "Users per page (max 100)"
+// @success 200 {object} dtos.PaginatedResponseDto{data=[]dtos.UserDto}	"users returned"
+// @Failure      400  {object}  dtos.FailedResponseDto	"query validation error or token not passed with request"
 // @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
 // @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
 // @Router       /users [get]
 func GetAllUsers(context *gin.Context) {
 
-	allUsers, err := services.GetAllUsers()
+	//validate query params
+	query := dtos.UserQueryDto{}
+	if err := context.ShouldBindQuery(&query); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	allUsers, pagination, err := services.GetAllUsers(query)
 
 	if err != nil {
 		exceptions.HandleInternalServerException(context)
 		return
 	}
 
-	Responses.HandleOkResponse(context, "All Users", allUsers)
+	Responses.HandleOkPaginatedResponse(context, "All Users", allUsers, pagination)
 }
 
 // GetUserByID godoc
 // @Summary      returns a user by its 16 caharcter uuid
-// @Description  get user by ID
+// @Description  get user by ID, users without the users:read permission can only get themselves
 // @Tags         User
 // @Security 	JWT
 // @Accept       json
@@ -85,6 +104,7 @@ func GetAllUsers(context *gin.Context) {
 // @success 200 {object} dtos.SuccessResponseDto{data=models.User} "desc"
 // @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error or token not passed with request"
 // @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"another user without the users:read permission"
 // @Failure      404  {object}  dtos.FailedResponseDto	"user with the specified ID not found"
 // @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
 // @Router       /users/{id} [get]
@@ -98,11 +118,20 @@ func GetUserByID(context *gin.Context) {
 		return
 	}
 
-	user, err := services.GetUserByID(params.ID)
+	user, err := services.GetUserByID(context, params.ID)
 
 	if err != nil {
-		exceptions.HandleNotFoundException(context, err)
-		return
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		}
 	}
 
 	Responses.HandleOkResponse(context, "User with ID: "+params.ID, user)
@@ -120,7 +149,9 @@ func GetUserByID(context *gin.Context) {
 // @success 200 {object} dtos.SuccessResponseDto{data=models.User}	"user updated successfully"
 // @Failure      400  {object}  dtos.FailedResponseDto	"request body/param validation error or token not passed with request"
 // @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"called with a personal access token or app token"
 // @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      409  {object}  dtos.FailedResponseDto	"another user with the specified email already exists"
 // @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
 // @Router       /users/{id} [put]
 func UpdateUser(context *gin.Context) {
@@ -149,6 +180,12 @@ func UpdateUser(context *gin.Context) {
 		case 404:
 			exceptions.HandleNotFoundException(context, err.Error)
 			return
+		case 409:
+			exceptions.HandleConflictException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
 		}
 	}
 
@@ -156,6 +193,253 @@ func UpdateUser(context *gin.Context) {
 
 }
 
+// ChangePassword godoc
+// @Summary      changes the password of a user
+// @Description  change password, requires the current password. All other logins are ended and new tokens are returned
+// @Tags         User
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @Param 		 data	body	dtos.ChangePasswordDto	true	"Current and New Password JSON"
+// @success 200 {object} dtos.SuccessResponseDto{data=dtos.TokenDto}	"password changed successfully"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body/param validation error, password rejected by the password policy or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"current password is incorrect or called with a personal access token or app token"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/password [put]
+func ChangePassword(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	// Validate Request Body
+	body := dtos.ChangePasswordDto{}
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	tokens, err := services.ChangePassword(context, params.ID, &body)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 400:
+			exceptions.HandleFieldValidationException(context, err.Error, err.FieldErrors)
+			return
+		case 401:
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+			return
+		case 403:
+			exceptions.HandleForbiddenException(context, err.Error.Error())
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Password Changed", tokens)
+}
+
+// UpdateUserRoles godoc
+// @Summary      replaces the roles of a user
+// @Description  set the roles of a user, tokens issued with the old roles are invalidated
+// @Tags         User
+// @Security 	JWT
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @Param 		 data	body	dtos.UpdateUserRolesDto	true	"User Roles JSON"
+// @success 200 {object} dtos.SuccessResponseDto{data=dtos.UserDto}	"user roles updated successfully"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request body/param validation error, unknown role or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the roles:manage permission"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      409  {object}  dtos.FailedResponseDto	"the last admin cannot lose the admin role"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/roles [put]
+func UpdateUserRoles(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	// Validate Request Body
+	body := dtos.UpdateUserRolesDto{}
+	if err := context.BindJSON(&body); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	user, err := services.SetUserRoles(params.ID, &body)
+
+	if err != nil {
+		handleRoleServiceError(context, err)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "User Roles Updated", user)
+}
+
+// UnlockUser godoc
+// @Summary      unlocks the login of a user
+// @Description  forget the failed login attempts of the account of a user
+// @Tags         User
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @success 200 {object} dtos.SuccessResponseDto	"user unlocked"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller is not an admin"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/unlock [post]
+func UnlockUser(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.UnlockUser(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "User Unlocked", nil)
+}
+
+// DisableUser godoc
+// @Summary      disables a user
+// @Description  a disabled user cannot log in and all of its tokens stop working
+// @Tags         User
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @success 200 {object} dtos.SuccessResponseDto{data=dtos.UserDto}	"user disabled"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the users:write permission"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      409  {object}  dtos.FailedResponseDto	"caller tried to disable its own account"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/disable [post]
+func DisableUser(context *gin.Context) {
+	setUserDisabled(context, true)
+}
+
+// EnableUser godoc
+// @Summary      enables a disabled user
+// @Description  the user can log in again, tokens from before it was disabled stay invalid
+// @Tags         User
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @success 200 {object} dtos.SuccessResponseDto{data=dtos.UserDto}	"user enabled"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the users:write permission"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/enable [post]
+func EnableUser(context *gin.Context) {
+	setUserDisabled(context, false)
+}
+
+func setUserDisabled(context *gin.Context, disabled bool) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	user, err := services.SetUserDisabled(context, params.ID, disabled)
+
+	if err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 401:
+			exceptions.HandleUnauthorizedException(context, err.Error.Error())
+			return
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		case 409:
+			exceptions.HandleConflictException(context, err.Error.Error())
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	if disabled {
+		Responses.HandleOkResponse(context, "User Disabled", user)
+		return
+	}
+
+	Responses.HandleOkResponse(context, "User Enabled", user)
+}
+
+// ForcePasswordReset godoc
+// @Summary      makes a user reset its password
+// @Description  end all logins of the user, refuse password logins until the password is reset and mail a reset link
+// @Tags         User
+// @Security 	JWT
+// @Produce      json
+// @Param        id   path      string  true  "User ID(UUID)"
+// @success 200 {object} dtos.SuccessResponseDto	"password reset required"
+// @Failure      400  {object}  dtos.FailedResponseDto	"request param validation error or token not passed with request"
+// @Failure      401  {object}  dtos.FailedResponseDto	"invalid/expired token"
+// @Failure      403  {object}  dtos.FailedResponseDto	"caller lacks the users:write permission"
+// @Failure      404  {object}  dtos.FailedResponseDto	"user with specified ID not found"
+// @Failure      500  {object}  dtos.FailedResponseDto	"unexpected internal server error"
+// @Router       /users/{id}/password-reset [post]
+func ForcePasswordReset(context *gin.Context) {
+
+	// Validate Request Params
+	params := dtos.EntityID{}
+	if err := context.BindUri(&params); err != nil {
+		exceptions.HandleValidationException(context, err)
+		return
+	}
+
+	if err := services.ForcePasswordReset(context, params.ID); err != nil {
+		switch statusCode := err.StatusCode; statusCode {
+		case 404:
+			exceptions.HandleNotFoundException(context, err.Error)
+			return
+		default:
+			exceptions.HandleInternalServerException(context)
+			return
+		}
+	}
+
+	Responses.HandleOkResponse(context, "Password Reset Required", nil)
+}
+
 // DeleteUser godoc
 // @Summary      deletes a user
 // @Description  delete user
@@ -0,0 +1,23 @@
+package dtos
+
+import "time"
+
+type AuditLogQueryDto struct {
+	Event     string `form:"event"`
+	UserID    string `form:"userId" binding:"omitempty,uuid"`
+	Email     string `form:"email"`
+	IPAddress string `form:"ip"`
+	Page      int    `form:"page" binding:"omitempty,gte=1"`
+	Limit     int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+}
+
+type AuditLogDto struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	UserID    string    `json:"userId,omitempty"`
+	ActorID   string    `json:"actorId,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IPAddress string    `json:"ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
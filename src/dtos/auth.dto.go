@@ -0,0 +1,63 @@
+package dtos
+
+import "time"
+
+type TokenDto struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+	// RecoveryCodes is only set when the login completed a forced two factor enrollment
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type RefreshTokenDto struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type LogoutDto struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type VerifyEmailDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordDto struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type MagicLinkDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkCallbackQueryDto struct {
+	Token string `form:"token" binding:"required"`
+}
+
+type SessionDto struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// Current is set for the session of the token the list was requested with
+	Current bool `json:"current"`
+}
+
+type LockoutDto struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Key          string    `json:"key"`
+	Failures     int       `json:"failures"`
+	BlockedUntil time.Time `json:"blockedUntil"`
+}
@@ -0,0 +1,14 @@
+package dtos
+
+type AutocompleteQueryDto struct {
+	Q     string `form:"q" binding:"required,min=2,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,gte=1,lte=25"`
+}
+
+// SuggestionDto is a single autocomplete match, ID is only set for movies
+type SuggestionDto struct {
+	Type  string  `json:"type"`
+	ID    string  `json:"id,omitempty"`
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
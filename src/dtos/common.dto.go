@@ -5,6 +5,27 @@ type EntityID struct {
 	ID string `uri:"id" binding:"required,uuid"`
 }
 
+// PaginationQueryDto binds page/limit and cursor based pagination query params
+type PaginationQueryDto struct {
+	Page   int    `form:"page" binding:"omitempty,gte=1"`
+	Limit  int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Cursor string `form:"cursor"`
+	// UseCursor is set by the controller when the cursor param is present, an empty cursor starts at the first page
+	UseCursor bool `form:"-"`
+}
+
+// PaginationDto describes the page of a listing, Page is 0 and the cursors are set when a cursor was used
+type PaginationDto struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"totalPages"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
 type SuccessResponseDto struct {
 	StatusText string      `json:"statusText"`
 	StatusCode int         `json:"statusCode"`
@@ -12,9 +33,18 @@ type SuccessResponseDto struct {
 	Data       interface{} `json:"data"`
 }
 
+type PaginatedResponseDto struct {
+	StatusText string        `json:"statusText"`
+	StatusCode int           `json:"statusCode"`
+	Message    string        `json:"message"`
+	Data       interface{}   `json:"data"`
+	Pagination PaginationDto `json:"pagination"`
+}
+
 type FailedResponseDto struct {
 	StatusText string
 	StatusCode int
 	ErrorType  string
 	Error      string
+	Fields     map[string][]string
 }
@@ -0,0 +1,14 @@
+package dtos
+
+type GenreDto struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CreateGenreDto struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateGenreDto struct {
+	Name string `json:"name" binding:"required"`
+}
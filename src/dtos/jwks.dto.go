@@ -0,0 +1,17 @@
+package dtos
+
+// JwkDto is a public key in JSON Web Key format (RFC 7517)
+type JwkDto struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JwksDto struct {
+	Keys []*JwkDto `json:"keys"`
+}
@@ -0,0 +1,10 @@
+package dtos
+
+type KeywordDto struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CreateKeywordDto struct {
+	Name string `json:"name" binding:"required"`
+}
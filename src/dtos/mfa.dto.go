@@ -0,0 +1,32 @@
+package dtos
+
+type MfaChallengeDto struct {
+	ChallengeToken     string `json:"challengeToken"`
+	EnrollmentRequired bool   `json:"enrollmentRequired"`
+	ExpiresIn          int    `json:"expiresIn"`
+}
+
+type MfaChallengeTokenDto struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+}
+
+type MfaVerifyDto struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code" binding:"required"`
+}
+
+type TotpCodeDto struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TotpEnrollmentDto struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	// QrCode is a PNG image of the otpauth URI as data URI
+	QrCode string `json:"qrCode"`
+}
+
+type RecoveryCodesDto struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -13,14 +13,67 @@ type GetMovie struct {
 	NrOfRatings int     `json:"nr_of_ratings"`
 }
 
+// MovieQueryDto binds the pagination, sorting and filtering query params of the movie listing
+type MovieQueryDto struct {
+	PaginationQueryDto
+	Sort      string  `form:"sort" binding:"omitempty,oneof=title year avg_rating created_at"`
+	Order     string  `form:"order" binding:"omitempty,oneof=asc desc"`
+	YearFrom  int     `form:"year_from" binding:"omitempty,gte=0"`
+	YearTo    int     `form:"year_to" binding:"omitempty,gte=0"`
+	Language  string  `form:"language"`
+	Director  string  `form:"director"`
+	MinRating float64 `form:"min_rating" binding:"omitempty,gte=0"`
+	MinLength int     `form:"min_length" binding:"omitempty,gte=0"`
+	MaxLength int     `form:"max_length" binding:"omitempty,gte=0"`
+	Decade    int     `form:"decade" binding:"omitempty,gte=0"`
+	// movies must have every listed genre and keyword
+	Genres   []string `form:"genre"`
+	Keywords []string `form:"keyword"`
+}
+
+type FacetDto struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type MovieFacetsDto struct {
+	Genres    []*FacetDto `json:"genres"`
+	Decades   []*FacetDto `json:"decades"`
+	Languages []*FacetDto `json:"languages"`
+}
+
+// MovieSearchQueryDto binds the query params of the movie search, q supports "quoted phrases" and prefix* terms
+type MovieSearchQueryDto struct {
+	Q     string `form:"q" binding:"required"`
+	Page  int    `form:"page" binding:"omitempty,gte=1"`
+	Limit int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+}
+
+type MovieSearchResultDto struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Language    string  `json:"language"`
+	Length      int     `json:"length"`
+	Year        int     `json:"year"`
+	Director    string  `json:"director"`
+	Actors      string  `json:"actors"`
+	AVGRating   float64 `json:"avg_rating"`
+	NrOfRatings int     `json:"nr_of_ratings"`
+	Rank        float64 `json:"rank"`
+	// Snippet is HTML, the plot is escaped and the matched words are wrapped in <mark> tags
+	Snippet string `json:"snippet"`
+}
+
 type CreateMovie struct {
-	Title    string `json:"title"`
-	Language string `json:"language"`
-	Length   int    `json:"length"`
-	Year     int    `json:"year"`
-	Director string `json:"director"`
-	Actors   string `json:"actors"`
-	Plot     string `json:"plot"`
+	Title    string   `json:"title"`
+	Language string   `json:"language"`
+	Length   int      `json:"length"`
+	Year     int      `json:"year"`
+	Director string   `json:"director"`
+	Actors   string   `json:"actors"`
+	Plot     string   `json:"plot"`
+	GenreIDs []string `json:"genreIds" binding:"omitempty,dive,uuid"`
+	Keywords []string `json:"keywords" binding:"omitempty,dive,required"`
 }
 
 type UpdateMovie struct {
@@ -31,6 +84,9 @@ type UpdateMovie struct {
 	Director string `json:"director"`
 	Actors   string `json:"actors"`
 	Plot     string `json:"plot"`
+	// GenreIDs and Keywords are left unchanged when omitted, an empty list removes them all
+	GenreIDs []string `json:"genreIds" binding:"omitempty,dive,uuid"`
+	Keywords []string `json:"keywords" binding:"omitempty,dive,required"`
 }
 
 type DeleteMovie struct {
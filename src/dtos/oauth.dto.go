@@ -0,0 +1,104 @@
+package dtos
+
+import "time"
+
+type CreateOAuthClientDto struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirectUris" binding:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	// Confidential clients run on a server and get a secret, public clients (mobile and single page apps) do not
+	Confidential bool `json:"confidential"`
+}
+
+type OAuthClientDto struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	ClientID     string    `json:"clientId"`
+	Confidential bool      `json:"confidential"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// CreatedOAuthClientDto is the only response that holds the client secret
+type CreatedOAuthClientDto struct {
+	OAuthClientDto
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// AuthorizationRequestDto binds the parameters of an authorization request, RFC 6749 section 4.1.1 with PKCE
+type AuthorizationRequestDto struct {
+	ResponseType        string `form:"response_type" json:"responseType" binding:"required"`
+	ClientID            string `form:"client_id" json:"clientId" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirectUri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"codeChallenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"codeChallengeMethod"`
+}
+
+// ConsentDto is what the consent screen shows the user before an app gets access
+type ConsentDto struct {
+	ClientName  string           `json:"clientName"`
+	ClientID    string           `json:"clientId"`
+	RedirectURI string           `json:"redirectUri"`
+	Scopes      []*PermissionDto `json:"scopes"`
+}
+
+type ConsentDecisionDto struct {
+	AuthorizationRequestDto
+	Approved bool `json:"approved"`
+}
+
+// AuthorizationResponseDto holds where the consent screen sends the browser, back to the app with a code or an error
+type AuthorizationResponseDto struct {
+	RedirectURI string `json:"redirectUri"`
+}
+
+// TokenRequestDto binds the form of a token request, RFC 6749 sections 4.1.3, 4.4.2 and 6
+type TokenRequestDto struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenDto is a successful token response, RFC 6749 section 5.1
+type OAuthTokenDto struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthErrorDto is an error response of the OAuth2 endpoints, RFC 6749 section 5.2
+type OAuthErrorDto struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// TokenOperationDto binds the form of an introspection (RFC 7662) or revocation (RFC 7009) request
+type TokenOperationDto struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectionDto is an introspection response, RFC 7662 section 2.2. Inactive tokens only have Active set
+type IntrospectionDto struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+}
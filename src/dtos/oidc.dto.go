@@ -0,0 +1,13 @@
+package dtos
+
+// OidcProviderDto uri is used for binding the provider name of OpenID Connect routes
+type OidcProviderDto struct {
+	Provider string `uri:"provider" binding:"required"`
+}
+
+type OidcCallbackQueryDto struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
@@ -0,0 +1,30 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/jaimy-monsuur/movie-api/src/webauthn"
+)
+
+type RegisterPasskeyDto struct {
+	Name       string                        `json:"name" binding:"required,max=100"`
+	Credential webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+type PasskeyLoginOptionsDto struct {
+	// Email limits the login to the passkeys of one user, without it the browser offers every passkey it has
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+type PasskeyLoginDto struct {
+	Credential webauthn.AssertionResponse `json:"credential" binding:"required"`
+}
+
+type PasskeyDto struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports"`
+	BackedUp   bool       `json:"backedUp"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
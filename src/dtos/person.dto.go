@@ -0,0 +1,56 @@
+package dtos
+
+type PeopleQueryDto struct {
+	Name  string `form:"name"`
+	Page  int    `form:"page" binding:"omitempty,gte=1"`
+	Limit int    `form:"limit" binding:"omitempty,gte=1,lte=100"`
+}
+
+type PersonDto struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CreditDto struct {
+	ID           string `json:"id"`
+	PersonID     string `json:"personId"`
+	Name         string `json:"name"`
+	RoleType     string `json:"roleType"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int    `json:"billingOrder,omitempty"`
+	Department   string `json:"department,omitempty"`
+	Job          string `json:"job,omitempty"`
+}
+
+type MovieCreditsDto struct {
+	Cast []*CreditDto `json:"cast"`
+	Crew []*CreditDto `json:"crew"`
+}
+
+type FilmographyEntryDto struct {
+	CreditID   string `json:"creditId"`
+	MovieID    string `json:"movieId"`
+	Title      string `json:"title"`
+	Year       int    `json:"year"`
+	RoleType   string `json:"roleType"`
+	Character  string `json:"character,omitempty"`
+	Department string `json:"department,omitempty"`
+	Job        string `json:"job,omitempty"`
+}
+
+// CreateCreditDto adds a credit to a movie, the person is referenced by PersonID or created by PersonName
+type CreateCreditDto struct {
+	PersonID     string `json:"personId" binding:"required_without=PersonName,omitempty,uuid"`
+	PersonName   string `json:"personName" binding:"required_without=PersonID"`
+	RoleType     string `json:"roleType" binding:"required,oneof=cast crew"`
+	Character    string `json:"character"`
+	BillingOrder int    `json:"billingOrder" binding:"omitempty,gte=1"`
+	Department   string `json:"department" binding:"required_if=RoleType crew"`
+	Job          string `json:"job" binding:"required_if=RoleType crew"`
+}
+
+// MovieCreditID uri is used for binding the params of a single credit of a movie
+type MovieCreditID struct {
+	ID       string `uri:"id" binding:"required,uuid"`
+	CreditID string `uri:"creditId" binding:"required,uuid"`
+}
@@ -0,0 +1,26 @@
+package dtos
+
+import "time"
+
+type CreatePersonalAccessTokenDto struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays defaults to 30 days
+	ExpiresInDays int `json:"expiresInDays" binding:"omitempty,gte=1,lte=365"`
+}
+
+type PersonalAccessTokenDto struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreatedPersonalAccessTokenDto is the only response that holds the token itself
+type CreatedPersonalAccessTokenDto struct {
+	PersonalAccessTokenDto
+	Token string `json:"token"`
+}
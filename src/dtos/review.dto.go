@@ -8,13 +8,23 @@ type GetReviewDto struct {
 	UserName  string  `json:"userName" binding:"required"`
 }
 
+// CreateReviewDto is the body of POST /reviews.
+// Deprecated: use CreateReviewV2Dto, the author is taken from the token and
+// UserName, MovieName and UserID are ignored apart from a check that UserID matches the token
 type CreateReviewDto struct {
 	Review    string  `json:"review" binding:"required"`
 	Rating    float32 `json:"rating" binding:"required"`
-	MovieName string  `json:"movieName" binding:"required"`
-	UserName  string  `json:"userName" binding:"required"`
-	MovieID   string  `json:"movieId" binding:"required"`
-	UserID    string  `json:"userId" binding:"required"`
+	MovieName string  `json:"movieName"`
+	UserName  string  `json:"userName"`
+	MovieID   string  `json:"movieId" binding:"required,uuid"`
+	UserID    string  `json:"userId" binding:"omitempty,uuid"`
+}
+
+// CreateReviewV2Dto is the body of POST /v2/reviews, the author is the authenticated user
+type CreateReviewV2Dto struct {
+	MovieID string  `json:"movieId" binding:"required,uuid"`
+	Review  string  `json:"review" binding:"required"`
+	Rating  float32 `json:"rating" binding:"required"`
 }
 
 type UpdateReviewDto struct {
@@ -22,6 +32,12 @@ type UpdateReviewDto struct {
 	Rating float32 `json:"rating" binding:"required"`
 }
 
+// UpsertReviewDto creates or replaces the review of the calling user for a movie
+type UpsertReviewDto struct {
+	Review string  `json:"review" binding:"required"`
+	Rating float32 `json:"rating" binding:"required"`
+}
+
 type DeleteReviewDto struct {
 	ReviewID string `json:"reviewId" binding:"required"`
 	UserID   string `json:"userId" binding:"required"`
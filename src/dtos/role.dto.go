@@ -0,0 +1,29 @@
+package dtos
+
+type RoleDto struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	BuiltIn     bool     `json:"builtIn"`
+	Permissions []string `json:"permissions"`
+}
+
+type PermissionDto struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateRoleDto struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type UpdateRoleDto struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type UpdateUserRolesDto struct {
+	Roles []string `json:"roles" binding:"required,min=1"`
+}
@@ -1,10 +1,12 @@
 package dtos
 
+import "time"
+
 type CreateUserDto struct {
 	FirstName string `json:"firstName" binding:"required"`
 	LastName  string `json:"lastName" binding:"required"`
 	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password" binding:"required,gte=6"`
+	Password  string `json:"password" binding:"required"`
 }
 
 type LoginUserDto struct {
@@ -18,10 +20,28 @@ type UpdateUserDto struct {
 	Email     string `json:"email" binding:"required,email"`
 }
 
+type ChangePasswordDto struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type UserQueryDto struct {
+	Email           string    `form:"email"`
+	Name            string    `form:"name"`
+	Role            string    `form:"role"`
+	LastLoginAfter  time.Time `form:"lastLoginAfter"`
+	LastLoginBefore time.Time `form:"lastLoginBefore"`
+	Disabled        *bool     `form:"disabled"`
+	Page            int       `form:"page" binding:"omitempty,gte=1"`
+	Limit           int       `form:"limit" binding:"omitempty,gte=1,lte=100"`
+}
+
 type UserDto struct {
-	ID        string `json:"id"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
-	Role      string `json:"role"`
+	ID         string     `json:"id"`
+	FirstName  string     `json:"firstName"`
+	LastName   string     `json:"lastName"`
+	Email      string     `json:"email"`
+	Roles      []string   `json:"roles"`
+	LastLogin  time.Time  `json:"lastLogin"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
 }
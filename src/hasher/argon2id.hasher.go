@@ -0,0 +1,109 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idPrefix = "$argon2id$"
+
+	// the defaults follow the second recommended option of RFC 9106 for memory constrained systems
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2idHasher stores hashes in the PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// argon2idParams are the parameters of a stored hash
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (hasher *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, hasher.Memory, hasher.Iterations,
+		hasher.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (hasher *Argon2idHasher) Verify(password string, encodedHash string) (bool, error) {
+	return verify(password, encodedHash)
+}
+
+func (hasher *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, err := decodeArgon2id(encodedHash)
+
+	return err != nil || params.memory != hasher.Memory || params.iterations != hasher.Iterations ||
+		params.parallelism != hasher.Parallelism || len(params.salt) != argon2SaltLength || len(params.key) != argon2KeyLength
+}
+
+func verifyArgon2id(password string, encodedHash string) (bool, error) {
+	params, err := decodeArgon2id(encodedHash)
+
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func decodeArgon2id(encodedHash string) (*argon2idParams, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	parts := strings.Split(encodedHash, "$")
+
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownHash
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+
+	params := &argon2idParams{}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+
+	if err != nil || params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return nil, fmt.Errorf("invalid argon2id parameters: %s", parts[3])
+	}
+
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, fmt.Errorf("invalid argon2id hash: %s", parts[5])
+	}
+
+	return params, nil
+}
@@ -0,0 +1,39 @@
+package hasher
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher stores bcrypt hashes, it is the algorithm older users were stored with
+type BcryptHasher struct {
+	Cost int
+}
+
+func (hasher *BcryptHasher) Hash(password string) (string, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(passwordHash), nil
+}
+
+func (hasher *BcryptHasher) Verify(password string, encodedHash string) (bool, error) {
+	return verify(password, encodedHash)
+}
+
+func (hasher *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	if !isBcryptHash(encodedHash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+
+	return err != nil || cost != hasher.Cost
+}
+
+func isBcryptHash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") || strings.HasPrefix(encodedHash, "$2b$") || strings.HasPrefix(encodedHash, "$2y$")
+}
@@ -0,0 +1,87 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher hashes passwords into strings that name their algorithm and parameters, the implementation
+// is chosen with the PASSWORD_HASHER environment variable
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify checks a password against a hash of any supported algorithm
+	Verify(password string, encodedHash string) (bool, error)
+	// NeedsRehash reports whether a hash was made with another algorithm or other parameters than Hash uses
+	NeedsRehash(encodedHash string) bool
+}
+
+// NewFromEnv returns the hasher configured by PASSWORD_HASHER: "argon2id" (the default) or "bcrypt"
+func NewFromEnv() (Hasher, error) {
+	switch hasherType := os.Getenv("PASSWORD_HASHER"); hasherType {
+	case "", "argon2id":
+		memory, err := envUint("ARGON2_MEMORY", defaultArgon2Memory)
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := envUint("ARGON2_ITERATIONS", defaultArgon2Iterations)
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envUint("ARGON2_PARALLELISM", defaultArgon2Parallelism)
+		if err != nil {
+			return nil, err
+		}
+		if parallelism > 255 {
+			return nil, fmt.Errorf("ARGON2_PARALLELISM must be at most 255, got %d", parallelism)
+		}
+		return &Argon2idHasher{Memory: memory, Iterations: iterations, Parallelism: uint8(parallelism)}, nil
+	case "bcrypt":
+		cost, err := envUint("BCRYPT_COST", uint32(bcrypt.DefaultCost))
+		if err != nil {
+			return nil, err
+		}
+		if int(cost) < bcrypt.MinCost || int(cost) > bcrypt.MaxCost {
+			return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+		}
+		return &BcryptHasher{Cost: int(cost)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported PASSWORD_HASHER: %v", hasherType)
+	}
+}
+
+// verify checks a password against an argon2id or bcrypt hash, telling them apart by their prefix
+func verify(password string, encodedHash string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encodedHash, argon2idPrefix):
+		return verifyArgon2id(password, encodedHash)
+	case isBcryptHash(encodedHash):
+		err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+func envUint(name string, fallback uint32) (uint32, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || parsed == 0 {
+		return 0, fmt.Errorf("invalid %s: %v", name, value)
+	}
+
+	return uint32(parsed), nil
+}
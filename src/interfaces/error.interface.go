@@ -3,4 +3,6 @@ package interfaces
 type ServiceError struct {
 	Error      error
 	StatusCode int
+	// FieldErrors holds the validation errors of each request field, when the error is about them
+	FieldErrors map[string][]string
 }
@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller of a request, placed in the gin context by the auth middleware
+type Principal struct {
+	UserID uuid.UUID
+	Email  string
+	Roles  []string
+	Scopes []string
+
+	EmailVerified bool
+
+	// TokenID and TokenExpiresAt identify the access token the principal authenticated with
+	TokenID        string
+	TokenExpiresAt time.Time
+
+	// SessionID is the login the access token was issued for, zero for tokens that are not a login
+	SessionID uuid.UUID
+
+	// PersonalAccessToken is set when the principal authenticated with a personal access token instead of a login
+	PersonalAccessToken bool
+
+	// OAuthClientID is set when the principal authenticated with a token issued to a third party app
+	OAuthClientID string
+}
+
+// Delegated reports whether the principal authenticated with a token handed to a script or app instead of a login
+func (principal *Principal) Delegated() bool {
+	return principal.PersonalAccessToken || principal.OAuthClientID != ""
+}
+
+func (principal *Principal) HasScope(scope string) bool {
+	for _, principalScope := range principal.Scopes {
+		if principalScope == scope {
+			return true
+		}
+	}
+	return false
+}
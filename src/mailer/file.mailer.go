@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file to Directory
+type FileMailer struct {
+	Directory string
+	From      string
+}
+
+func (mailer *FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(mailer.Directory, 0o755); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), message.To)
+
+	return os.WriteFile(filepath.Join(mailer.Directory, fileName), format(mailer.From, message), 0o644)
+}
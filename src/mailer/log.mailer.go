@@ -0,0 +1,14 @@
+package mailer
+
+import "log"
+
+// LogMailer prints every email to the log instead of sending it
+type LogMailer struct {
+	From string
+}
+
+func (mailer *LogMailer) Send(message Message) error {
+	log.Printf("email to %s\n%s", message.To, format(mailer.From, message))
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails, the implementation is chosen with the MAILER environment variable
+type Mailer interface {
+	Send(message Message) error
+}
+
+// NewFromEnv returns the mailer configured by MAILER: "smtp", "file" or "log" (the default)
+func NewFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@movie-api.local"
+	}
+
+	switch mailerType := os.Getenv("MAILER"); mailerType {
+	case "smtp":
+		return &SmtpMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		directory := os.Getenv("MAIL_DIR")
+		if directory == "" {
+			directory = "mail"
+		}
+		return &FileMailer{Directory: directory, From: from}, nil
+	case "", "log":
+		return &LogMailer{From: from}, nil
+	default:
+		return nil, fmt.Errorf("unsupported MAILER: %v", mailerType)
+	}
+}
+
+// format renders a message as a plain text email
+func format(from string, message Message) []byte {
+	return []byte("From: " + from + "\r\n" +
+		"To: " + message.To + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		message.Body + "\r\n")
+}
@@ -0,0 +1,23 @@
+package mailer
+
+import "net/smtp"
+
+// SmtpMailer sends emails through an SMTP server, authentication is skipped when Username is empty
+// so it also works against a local SMTP catcher
+type SmtpMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer *SmtpMailer) Send(message Message) error {
+	var auth smtp.Auth
+
+	if mailer.Username != "" {
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	}
+
+	return smtp.SendMail(mailer.Host+":"+mailer.Port, auth, mailer.From, []string{message.To}, format(mailer.From, message))
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"log"
+	"os"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jaimy-monsuur/movie-api/src/config"
 	_ "github.com/jaimy-monsuur/movie-api/src/docs"
@@ -12,22 +16,49 @@ import (
 func init() {
 	config.LoadEnvVariables()
 	config.ConnectToDB()
+	config.SetupMailer()
+	config.SetupPasswordHasher()
+	config.SetupPasswordPolicy()
+	config.SetupWebauthn()
 }
 
 func main() {
 
 	router := gin.Default()
 
+	// logins are throttled per client IP, so forwarded headers are only trusted from known proxies
+	if err := router.SetTrustedProxies(strings.Fields(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+
 	routes.IndexRoutes(router)
 
 	routes.UserRoutes(router)
 
 	routes.AuthRoutes(router)
 
+	routes.OAuthRoutes(router)
+
+	routes.WellKnownRoutes(router)
+
+	routes.RoleRoutes(router)
+
+	routes.AuditRoutes(router)
+
 	routes.MovieRoutes(router)
 
 	routes.ReviewRoutes(router)
 
+	routes.ReviewRoutesV2(router)
+
+	routes.PeopleRoutes(router)
+
+	routes.GenreRoutes(router)
+
+	routes.KeywordRoutes(router)
+
+	routes.AutocompleteRoutes(router)
+
 	router.GET("/api-docs/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	router.Run()
@@ -13,40 +13,98 @@ func Auth() gin.HandlerFunc {
 
 	return func(context *gin.Context) {
 
-		bearerToken := context.GetHeader("Authorization")
-		if bearerToken == "" {
-			exceptions.HandleBadRequestException(context, errors.New("bearer token is required"))
+		if !authenticate(context) {
 			return
 		}
+		context.Next()
+	}
+}
 
-		accessToken := strings.Split(bearerToken, "Bearer ")[1]
-		err := services.ValidateToken(accessToken)
-		if err != nil {
+// RequirePermission only lets requests through when the principal has all of the permissions,
+// the permissions of a principal are the scopes of its token
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 
-			exceptions.HandleUnauthorizedException(context, "Unauthorized")
+	return func(context *gin.Context) {
+
+		if !authenticate(context) {
 			return
 		}
+
+		principal, _ := services.GetPrincipal(context)
+		for _, permission := range permissions {
+			if !principal.HasScope(permission) {
+				exceptions.HandleForbiddenException(context, "Missing permission: "+permission)
+				return
+			}
+		}
+
 		context.Next()
 	}
 }
 
-func AdminAuth() gin.HandlerFunc {
+// RequireVerifiedEmail only lets requests through when the principal verified its email address,
+// it must come after a middleware that authenticates the request
+func RequireVerifiedEmail() gin.HandlerFunc {
 
 	return func(context *gin.Context) {
 
-		bearerToken := context.GetHeader("Authorization")
-		if bearerToken == "" {
-			exceptions.HandleBadRequestException(context, errors.New("bearer token is required"))
+		principal, err := services.GetPrincipal(context)
+		if err != nil {
+			exceptions.HandleUnauthorizedException(context, "Unauthorized")
 			return
 		}
 
-		accessToken := strings.Split(bearerToken, "Bearer ")[1]
-		err := services.ValidateAdminToken(accessToken)
-		if err != nil {
+		if !principal.EmailVerified {
+			exceptions.HandleForbiddenException(context, "Email address is not verified")
+			return
+		}
+		context.Next()
+	}
+}
 
+// RequireLogin only lets requests through when the principal authenticated with a login, personal access tokens
+// and app tokens cannot manage the account whatever their scopes, it must come after a middleware that authenticates the request
+func RequireLogin() gin.HandlerFunc {
+
+	return func(context *gin.Context) {
+
+		principal, err := services.GetPrincipal(context)
+		if err != nil {
 			exceptions.HandleUnauthorizedException(context, "Unauthorized")
 			return
 		}
+
+		if principal.Delegated() {
+			exceptions.HandleForbiddenException(context, "Personal access tokens and app tokens cannot manage the account")
+			return
+		}
 		context.Next()
 	}
 }
+
+// authenticate validates the bearer token, a JWT or a personal access token, and places the principal in the context,
+// the request is aborted and false returned when the token is missing or invalid
+func authenticate(context *gin.Context) bool {
+
+	// an earlier middleware of the route already authenticated the request
+	if _, err := services.GetPrincipal(context); err == nil {
+		return true
+	}
+
+	bearerToken := context.GetHeader("Authorization")
+	if !strings.HasPrefix(bearerToken, "Bearer ") {
+		exceptions.HandleBadRequestException(context, errors.New("bearer token is required"))
+		return false
+	}
+
+	accessToken := strings.TrimPrefix(bearerToken, "Bearer ")
+	principal, err := services.AuthenticateToken(accessToken)
+	if err != nil {
+
+		exceptions.HandleUnauthorizedException(context, "Unauthorized")
+		return false
+	}
+
+	services.SetPrincipal(context, principal)
+	return true
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"log"
+
+	"github.com/google/uuid"
 	"github.com/jaimy-monsuur/movie-api/src/config"
 	"github.com/jaimy-monsuur/movie-api/src/models"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -11,6 +16,143 @@ func init() {
 }
 
 func main() {
-	config.DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";")
-	config.DB.AutoMigrate(&models.Movie{}, &models.Review{}, &models.User{})
+	if err := config.DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";").Error; err != nil {
+		log.Fatalf("Failed to create the uuid-ossp extension: %v", err)
+	}
+
+	// keep only the latest review of a user per movie, so the unique index on reviews can be created,
+	// this only runs until the index exists
+	var dedupedMovieIDs []uuid.UUID
+
+	if config.DB.Migrator().HasTable(&models.Review{}) && !config.DB.Migrator().HasIndex(&models.Review{}, "idx_reviews_user_movie") {
+		var err error
+		if dedupedMovieIDs, err = removeDuplicateReviews(); err != nil {
+			log.Fatalf("Failed to remove duplicate reviews: %v", err)
+		}
+	}
+
+	// users that registered before email verification existed are treated as verified
+	grandfatherVerifiedEmails := config.DB.Migrator().HasTable(&models.User{}) &&
+		!config.DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	// users from before roles existed keep their role through the user_roles table
+	migrateLegacyRoles := config.DB.Migrator().HasColumn(&models.User{}, "role")
+
+	err := config.DB.AutoMigrate(&models.Movie{}, &models.Review{}, &models.User{}, &models.Person{}, &models.Credit{}, &models.Genre{}, &models.Keyword{},
+		&models.RefreshToken{}, &models.RevokedToken{}, &models.SigningKey{}, &models.UserToken{}, &models.LoginThrottle{}, &models.AuditLog{}, &models.RecoveryCode{},
+		&models.ExternalIdentity{}, &models.OidcLoginState{}, &models.PersonalAccessToken{}, &models.Permission{}, &models.Role{},
+		&models.Passkey{}, &models.PasskeyChallenge{}, &models.MagicLink{}, &models.Session{},
+		&models.OAuthClient{}, &models.OAuthAuthorizationCode{}, &models.OAuthToken{})
+	if err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
+	if err := services.SeedRoles(); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	if migrateLegacyRoles {
+		err := config.DB.Exec(`INSERT INTO user_roles (user_id, role_id)
+			SELECT users.id, roles.id FROM users JOIN roles
+			ON roles.name = CASE WHEN users.role = 'admin' THEN 'admin' ELSE 'reviewer' END
+			ON CONFLICT DO NOTHING;`).Error
+		if err != nil {
+			log.Fatalf("Failed to migrate user roles: %v", err)
+		}
+
+		if err := config.DB.Migrator().DropColumn(&models.User{}, "role"); err != nil {
+			log.Fatalf("Failed to drop the role column of users: %v", err)
+		}
+	}
+
+	if grandfatherVerifiedEmails {
+		if err := config.DB.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;").Error; err != nil {
+			log.Fatalf("Failed to mark the emails of existing users as verified: %v", err)
+		}
+	}
+
+	// weighted full text search document of a movie, kept up to date by postgres itself
+	// accent and case insensitive trigram indexes used by the autocomplete, unaccent is only
+	// stable so it is wrapped in an immutable function to be usable in an index expression
+	searchStatements := []string{
+		`ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(director, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(actors, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(plot, '')), 'C')
+	) STORED;`,
+		"CREATE INDEX IF NOT EXISTS idx_movies_search_vector ON movies USING GIN (search_vector);",
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm;",
+		"CREATE EXTENSION IF NOT EXISTS unaccent;",
+		`CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text AS
+		$$ SELECT public.unaccent('public.unaccent', $1) $$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;`,
+		"CREATE INDEX IF NOT EXISTS idx_movies_title_trgm ON movies USING GIN (immutable_unaccent(lower(title)) gin_trgm_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_movies_director_trgm ON movies USING GIN (immutable_unaccent(lower(director)) gin_trgm_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_movies_actors_trgm ON movies USING GIN (immutable_unaccent(lower(actors)) gin_trgm_ops);",
+	}
+
+	for _, statement := range searchStatements {
+		if err := config.DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to set up movie search: %v", err)
+		}
+	}
+
+	// ratings changed for the movies that lost duplicate reviews
+	for _, movieID := range dedupedMovieIDs {
+		if _, err := services.RecomputeMovieRating(movieID.String()); err != nil && err.StatusCode != 404 {
+			log.Fatalf("Failed to recompute the rating of movie %s: %v", movieID, err.Error)
+		}
+	}
+
+	// turn the comma separated director and actors of existing movies into people and credits
+	backfilled, err := services.BackfillCredits()
+	if err != nil {
+		log.Fatalf("Failed to backfill credits after %d movies: %v", backfilled, err)
+	}
+}
+
+// removeDuplicateReviews deletes all but the latest review of a user per movie and returns the movies that lost
+// reviews. The deleted reviews are copied to the duplicate_reviews table and logged, so they can be restored
+func removeDuplicateReviews() ([]uuid.UUID, error) {
+	var removed []struct {
+		ID      uuid.UUID
+		UserID  uuid.UUID
+		MovieID uuid.UUID
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE IF NOT EXISTS duplicate_reviews (LIKE reviews);").Error; err != nil {
+			return err
+		}
+
+		err := tx.Raw(`WITH removed AS (
+				DELETE FROM reviews USING reviews newer
+				WHERE reviews.user_id = newer.user_id AND reviews.movie_id = newer.movie_id
+				AND (reviews.created_at, reviews.id) < (newer.created_at, newer.id)
+				RETURNING reviews.*
+			), archived AS (
+				INSERT INTO duplicate_reviews SELECT DISTINCT * FROM removed
+			)
+			SELECT DISTINCT id, user_id, movie_id FROM removed;`).Scan(&removed).Error
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	movieIDs := []uuid.UUID{}
+	seenMovieIDs := map[uuid.UUID]bool{}
+
+	for _, review := range removed {
+		log.Printf("Removed duplicate review %s of user %s for movie %s, it is kept in duplicate_reviews", review.ID, review.UserID, review.MovieID)
+
+		if !seenMovieIDs[review.MovieID] {
+			seenMovieIDs[review.MovieID] = true
+			movieIDs = append(movieIDs, review.MovieID)
+		}
+	}
+
+	return movieIDs, nil
 }
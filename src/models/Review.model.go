@@ -5,8 +5,8 @@ import "github.com/google/uuid"
 type Review struct {
 	Base
 	Content string
-	MovieID uuid.UUID
-	UserID  uuid.UUID `gorm:"index"`
+	MovieID uuid.UUID `gorm:"uniqueIndex:idx_reviews_user_movie"`
+	UserID  uuid.UUID `gorm:"index;uniqueIndex:idx_reviews_user_movie"`
 	User    User
 	Rating  float64
 }
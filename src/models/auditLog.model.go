@@ -0,0 +1,35 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	AuditLoginSucceeded      = "login.succeeded"
+	AuditLoginFailed         = "login.failed"
+	AuditLoginBlocked        = "login.blocked"
+	AuditLoginLocked         = "login.locked"
+	AuditLockoutCleared      = "lockout.cleared"
+	AuditMfaEnabled          = "mfa.enabled"
+	AuditMfaDisabled         = "mfa.disabled"
+	AuditRecoveryCodeUsed    = "mfa.recovery_code_used"
+	AuditIdentityLinked      = "identity.linked"
+	AuditUserDisabled        = "user.disabled"
+	AuditUserEnabled         = "user.enabled"
+	AuditPasswordResetForced = "password.reset_forced"
+	AuditPasskeyAdded        = "passkey.added"
+	AuditPasskeyRemoved      = "passkey.removed"
+	AuditMagicLinkSent       = "magic_link.sent"
+	AuditSessionRevoked      = "session.revoked"
+	AuditOAuthConsentGranted = "oauth.consent_granted"
+)
+
+// AuditLog records a security relevant event. It has no foreign keys, so the history
+// of a user is kept after the user is deleted
+type AuditLog struct {
+	Base
+	Event     string     `gorm:"not null;index"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	ActorID   *uuid.UUID `gorm:"type:uuid"`
+	Email     string     `gorm:"index"`
+	IPAddress string
+	Detail    string
+}
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	CreditRoleCast = "cast"
+	CreditRoleCrew = "crew"
+
+	DirectingDepartment = "Directing"
+	DirectorJob         = "Director"
+)
+
+// Credit links a person to a movie, cast credits use Character and BillingOrder,
+// crew credits use Department and Job
+type Credit struct {
+	Base
+	MovieID      uuid.UUID `gorm:"not null;index"`
+	Movie        Movie     `gorm:"constraint:OnDelete:CASCADE"`
+	PersonID     uuid.UUID `gorm:"not null;index"`
+	Person       Person    `gorm:"constraint:OnDelete:CASCADE"`
+	RoleType     string    `gorm:"not null"`
+	Character    string
+	BillingOrder int
+	Department   string
+	Job          string
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links a user to its account (subject) at an OpenID Connect provider
+type ExternalIdentity struct {
+	Base
+	UserID   uuid.UUID `gorm:"not null;index"`
+	User     User      `gorm:"constraint:OnDelete:CASCADE"`
+	Provider string    `gorm:"not null;uniqueIndex:idx_external_identities_provider_subject"`
+	Subject  string    `gorm:"not null;uniqueIndex:idx_external_identities_provider_subject"`
+	Email    string
+}
@@ -0,0 +1,6 @@
+package models
+
+type Keyword struct {
+	Base
+	Name string `gorm:"not null;uniqueIndex"`
+}
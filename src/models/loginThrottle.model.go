@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+)
+
+// LoginThrottle counts the recent failed logins of an email address or an IP address,
+// logins are refused until BlockedUntil
+type LoginThrottle struct {
+	Base
+	Kind          string     `gorm:"not null;uniqueIndex:idx_login_throttles_kind_key"`
+	Key           string     `gorm:"not null;uniqueIndex:idx_login_throttles_kind_key"`
+	Failures      int        `gorm:"not null;default:0"`
+	LastFailureAt time.Time  `gorm:"not null"`
+	BlockedUntil  *time.Time `gorm:"index"`
+}
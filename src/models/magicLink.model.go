@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MagicLink is a single use login link mailed to a user, it can be bound to the IP address and the browser
+// that asked for it. Only SHA-256 hashes of the token and of the device secret are stored
+type MagicLink struct {
+	Base
+	UserID    uuid.UUID `gorm:"not null;index"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	// Email is the normalized address the link was sent to, links are rate limited per address
+	Email      string `gorm:"not null;index"`
+	IPAddress  string `gorm:"not null;index"`
+	DeviceHash string
+	ExpiresAt  time.Time `gorm:"not null"`
+	UsedAt     *time.Time
+}
@@ -6,10 +6,13 @@ type Movie struct {
 	Language    string
 	Length      int
 	Year        int
-	Director    string
-	Actors      string
+	Director    string // comma separated, kept in sync with the director credits
+	Actors      string // comma separated, kept in sync with the cast credits
 	Plot        string
-	AVGRating   float64  `gorm:"default:0"`
-	NrOfRatings int      `gorm:"default:0"`
-	Reviews     []Review `gorm:"foreignKey:MovieID"`
+	AVGRating   float64   `gorm:"default:0"`
+	NrOfRatings int       `gorm:"default:0"`
+	Reviews     []Review  `gorm:"foreignKey:MovieID"`
+	Credits     []Credit  `gorm:"foreignKey:MovieID;constraint:OnDelete:CASCADE"`
+	Genres      []Genre   `gorm:"many2many:movie_genres;constraint:OnDelete:CASCADE"`
+	Keywords    []Keyword `gorm:"many2many:movie_keywords;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// OAuthClient is a third party app that acts on behalf of users through the OAuth2 authorization server.
+// Confidential clients authenticate with a secret, only its SHA-256 hash is stored
+type OAuthClient struct {
+	Base
+	// OwnerID is the user that registered the app, client credentials tokens act on its behalf
+	OwnerID      uuid.UUID `gorm:"not null;index"`
+	Owner        User      `gorm:"constraint:OnDelete:CASCADE"`
+	Name         string    `gorm:"not null"`
+	ClientID     string    `gorm:"not null;uniqueIndex"`
+	SecretHash   string
+	Confidential bool `gorm:"not null"`
+	// RedirectURIs and Scopes are space delimited, Scopes are the most an app can ask for
+	RedirectURIs string `gorm:"not null"`
+	Scopes       string `gorm:"not null"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OAuthTokenAccess  = "access"
+	OAuthTokenRefresh = "refresh"
+)
+
+// OAuthAuthorizationCode is handed to an app after the user consented, the app exchanges it once for tokens
+// with the PKCE verifier behind CodeChallenge. Only the SHA-256 hash of the code is stored
+type OAuthAuthorizationCode struct {
+	Base
+	ClientID      uuid.UUID   `gorm:"not null;index"`
+	Client        OAuthClient `gorm:"constraint:OnDelete:CASCADE"`
+	UserID        uuid.UUID   `gorm:"not null;index"`
+	User          User        `gorm:"constraint:OnDelete:CASCADE"`
+	CodeHash      string      `gorm:"not null;uniqueIndex"`
+	RedirectURI   string      `gorm:"not null"`
+	Scopes        string      `gorm:"not null"`
+	CodeChallenge string      `gorm:"not null"`
+	// RedirectURIGiven is set when the authorization request named the redirect URI instead of using the only one
+	// the app registered, the token request then has to name it as well
+	RedirectURIGiven bool `gorm:"not null;default:false"`
+	// GrantID is given to the tokens the code is exchanged for, so they can be revoked when the code is used twice
+	GrantID   uuid.UUID `gorm:"type:uuid;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// OAuthToken is an opaque access or refresh token issued to an app. Tokens of one authorization share a GrantID,
+// revoking a refresh token revokes the whole grant. Only the SHA-256 hash of the token is stored
+type OAuthToken struct {
+	Base
+	ClientID  uuid.UUID   `gorm:"not null;index"`
+	Client    OAuthClient `gorm:"constraint:OnDelete:CASCADE"`
+	UserID    uuid.UUID   `gorm:"not null;index"`
+	User      User        `gorm:"constraint:OnDelete:CASCADE"`
+	GrantID   uuid.UUID   `gorm:"type:uuid;not null;index"`
+	Kind      string      `gorm:"not null"`
+	TokenHash string      `gorm:"not null;uniqueIndex"`
+	// Scopes is space delimited, like the scope claim of an access token
+	Scopes    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// OidcLoginState remembers a started OpenID Connect login until the provider redirects back,
+// only the SHA-256 hash of the state is stored
+type OidcLoginState struct {
+	Base
+	Provider     string    `gorm:"not null"`
+	StateHash    string    `gorm:"not null;uniqueIndex"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential a user logs in with instead of a password, a user can have several
+type Passkey struct {
+	Base
+	UserID       uuid.UUID `gorm:"not null;index"`
+	User         User      `gorm:"constraint:OnDelete:CASCADE"`
+	Name         string    `gorm:"not null"`
+	CredentialID []byte    `gorm:"not null;uniqueIndex"`
+	// PublicKey is COSE encoded, as the authenticator sent it
+	PublicKey []byte `gorm:"not null"`
+	// SignCount has to increase with every login, a counter that goes back means the credential was cloned
+	SignCount int64 `gorm:"not null;default:0"`
+	AAGUID    []byte
+	// Transports is space delimited, they are hints for the browser where to find the credential
+	Transports     string
+	BackupEligible bool `gorm:"not null;default:false"`
+	BackedUp       bool `gorm:"not null;default:false"`
+	LastUsedAt     *time.Time
+}
+
+const (
+	PasskeyChallengeRegistration = "registration"
+	PasskeyChallengeLogin        = "login"
+)
+
+// PasskeyChallenge remembers a started passkey registration or login until the browser answers it,
+// only the SHA-256 hash of the challenge is stored
+type PasskeyChallenge struct {
+	Base
+	Purpose string `gorm:"not null"`
+	// UserID is the registering user, or the user a login was started for when it was started with an email
+	UserID        *uuid.UUID `gorm:"type:uuid"`
+	ChallengeHash string     `gorm:"not null;uniqueIndex"`
+	ExpiresAt     time.Time  `gorm:"not null;index"`
+}
@@ -0,0 +1,22 @@
+package models
+
+const (
+	PermissionMoviesRead      = "movies:read"
+	PermissionMoviesWrite     = "movies:write"
+	PermissionMoviesDelete    = "movies:delete"
+	PermissionReviewsRead     = "reviews:read"
+	PermissionReviewsWrite    = "reviews:write"
+	PermissionReviewsModerate = "reviews:moderate"
+	PermissionUsersRead       = "users:read"
+	PermissionUsersWrite      = "users:write"
+	PermissionUsersDelete     = "users:delete"
+	PermissionRolesManage     = "roles:manage"
+	PermissionAuditRead       = "audit:read"
+)
+
+// Permission allows an action on the API, the permissions of a user are the scopes of its tokens
+type Permission struct {
+	Base
+	Name        string `gorm:"not null;uniqueIndex"`
+	Description string
+}
@@ -0,0 +1,7 @@
+package models
+
+type Person struct {
+	Base
+	Name    string   `gorm:"not null;index"`
+	Credits []Credit `gorm:"foreignKey:PersonID;constraint:OnDelete:CASCADE"`
+}
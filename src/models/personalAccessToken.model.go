@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken is a long lived token a user creates for scripts and integrations, limited to
+// a set of scopes. Only the SHA-256 hash of the token is stored
+type PersonalAccessToken struct {
+	Base
+	UserID    uuid.UUID `gorm:"not null;index"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	Name      string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	// Scopes is space delimited, like the scope claim of an access token
+	Scopes     string    `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
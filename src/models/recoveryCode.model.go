@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a single use replacement for a TOTP code, only the SHA-256 hash of the code is stored
+type RecoveryCode struct {
+	Base
+	UserID   uuid.UUID `gorm:"not null;index"`
+	User     User      `gorm:"constraint:OnDelete:CASCADE"`
+	CodeHash string    `gorm:"not null"`
+	UsedAt   *time.Time
+}
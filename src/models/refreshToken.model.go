@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a single use token, every refresh replaces it with a new token of the same family.
+// Only the SHA-256 hash of the token is stored
+type RefreshToken struct {
+	Base
+	UserID       uuid.UUID `gorm:"not null;index"`
+	User         User      `gorm:"constraint:OnDelete:CASCADE"`
+	FamilyID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash    string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	ReplacedByID *uuid.UUID `gorm:"type:uuid"`
+}
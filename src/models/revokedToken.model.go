@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RevokedToken is an access token that was revoked before it expired, it can be removed once expired
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,19 @@
+package models
+
+const (
+	RoleViewer        = "viewer"
+	RoleReviewer      = "reviewer"
+	RoleModerator     = "moderator"
+	RoleCatalogEditor = "catalog_editor"
+	RoleAdmin         = "admin"
+)
+
+// Role is a named set of permissions assigned to users, built-in roles are created by the
+// migrations and cannot be changed through the API
+type Role struct {
+	Base
+	Name        string `gorm:"not null;uniqueIndex"`
+	Description string
+	BuiltIn     bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+}
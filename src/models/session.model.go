@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login on a device. Its ID is the family ID of the refresh tokens of the login and part of
+// every access token issued for it, so revoking the session ends the login right away
+type Session struct {
+	Base
+	UserID     uuid.UUID `gorm:"not null;index"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE"`
+	UserAgent  string    `gorm:"not null"`
+	IPAddress  string    `gorm:"not null"`
+	LastSeenAt time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
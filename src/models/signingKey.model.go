@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair used to sign tokens, its ID is the kid of the tokens it signs.
+// A key signs tokens between ActivatesAt and RetiresAt and verifies them until ExpiresAt.
+// The private key is stored encrypted
+type SigningKey struct {
+	Base
+	Algorithm   string    `gorm:"not null"`
+	PrivateKey  []byte    `gorm:"not null"`
+	PublicKey   []byte    `gorm:"not null"`
+	ActivatesAt time.Time `gorm:"not null;index"`
+	RetiresAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
@@ -2,10 +2,10 @@ package models
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/jaimy-monsuur/movie-api/src/config"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -16,31 +16,93 @@ type User struct {
 	LastName  string
 	LastLogin time.Time `gorm:"default:CURRENT_TIMESTAMP"`
 	Password  string    `gorm:"not null"`
-	Role      string    `gorm:"default:'user'"`
+	Roles     []Role    `gorm:"many2many:user_roles;constraint:OnDelete:CASCADE"`
+	// EmailVerifiedAt is set once the user proved to own the email address
+	EmailVerifiedAt *time.Time
+	// TotpSecret is encrypted, two factor authentication is on once TotpEnabledAt is set
+	TotpSecret    []byte
+	TotpEnabledAt *time.Time
+	// TotpLastStep is the time step of the last accepted code, so a code cannot be used twice
+	TotpLastStep int64 `gorm:"not null;default:0"`
+	// DisabledAt is set while the account is disabled, it can neither log in nor use its tokens
+	DisabledAt *time.Time
+	// PasswordResetRequired blocks password logins until the password is reset with an emailed link
+	PasswordResetRequired bool `gorm:"not null;default:false"`
+	// TokenVersion is part of every issued token, bumping it invalidates all older tokens of the user
+	TokenVersion int `gorm:"not null;default:1"`
 }
 
-func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
+var ErrPasswordMismatch = errors.New("password does not match")
 
-	passwordHash, hashError := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if hashError != nil {
-		err = errors.New(err.Error())
+func (user *User) BeforeCreate(tx *gorm.DB) error {
+
+	passwordHash, err := HashPassword(user.Password)
+	if err != nil {
+		return err
 	}
 
-	user.Password = string(passwordHash)
+	user.Password = passwordHash
+
+	return nil
+}
 
-	return
+// HashPassword returns the hash stored in place of a plain text password
+func HashPassword(password string) (string, error) {
+	return config.PasswordHasher.Hash(password)
 }
 
-func (user *User) ValidatePassword(providedPassword string) error {
+// ComparePassword checks a password against the stored hash without recording a login
+func (user *User) ComparePassword(providedPassword string) error {
 
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(providedPassword))
+	matches, err := config.PasswordHasher.Verify(providedPassword, user.Password)
 	if err != nil {
 		return err
 	}
 
+	if !matches {
+		return ErrPasswordMismatch
+	}
+
+	return nil
+}
+
+// ValidatePassword checks the password of a login, a hash made with an outdated algorithm or
+// parameters is replaced while the plain text password is at hand
+func (user *User) ValidatePassword(providedPassword string) error {
+
+	if err := user.ComparePassword(providedPassword); err != nil {
+		return err
+	}
+
 	user.LastLogin = time.Now()
 
-	config.DB.Save(&user)
+	// only last_login is written, saving the whole user could undo a concurrent token version bump
+	config.DB.Model(user).Update("last_login", user.LastLogin)
+
+	if config.PasswordHasher.NeedsRehash(user.Password) {
+		user.rehashPassword(providedPassword)
+	}
 
 	return nil
 }
+
+// rehashPassword stores a new hash of the password, unless the password changed in the meantime
+func (user *User) rehashPassword(password string) {
+
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash the password of user %s: %v", user.ID, err)
+		return
+	}
+
+	err = config.DB.Model(&User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Update("password", passwordHash).Error
+
+	if err != nil {
+		log.Printf("Failed to store the rehashed password of user %s: %v", user.ID, err)
+		return
+	}
+
+	user.Password = passwordHash
+}
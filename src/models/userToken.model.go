@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserTokenEmailVerification = "email_verification"
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenMfaChallenge      = "mfa_challenge"
+)
+
+// UserToken is a single use token, mailed to a user to verify the email address or reset the password,
+// or handed out after the password step of a login that needs a second factor. Only the SHA-256 hash
+// of the token is stored
+type UserToken struct {
+	Base
+	UserID    uuid.UUID `gorm:"not null;index"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	Purpose   string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
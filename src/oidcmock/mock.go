@@ -0,0 +1,203 @@
+// Command oidcmock is a minimal OpenID Connect provider to try the OIDC login locally. Every
+// authorization request is approved right away for the user configured with MOCK_OIDC_* variables.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const keyID = "mock"
+
+// authorization is a handed out code waiting to be exchanged for tokens
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+var (
+	issuer     = getEnv("MOCK_OIDC_ISSUER", "http://localhost:9000")
+	signingKey *rsa.PrivateKey
+
+	authorizations = struct {
+		sync.Mutex
+		byCode map[string]*authorization
+	}{byCode: map[string]*authorization{}}
+)
+
+func main() {
+	var err error
+
+	if signingKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		log.Fatal("Failed to generate signing key: ", err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+	http.HandleFunc("/jwks", jwks)
+
+	address := getEnv("MOCK_OIDC_ADDRESS", ":9000")
+
+	log.Printf("Mock OIDC provider %s listening on %s", issuer, address)
+	log.Fatal(http.ListenAndServe(address, nil))
+}
+
+func discovery(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize approves every request and redirects back with a code
+func authorize(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+
+	if err != nil || query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		http.Error(writer, "expected an authorization code request with an S256 code challenge", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	authorizations.Lock()
+	authorizations.byCode[code] = &authorization{
+		clientID:      query.Get("client_id"),
+		redirectURI:   redirectURI.String(),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	authorizations.Unlock()
+
+	callbackQuery := redirectURI.Query()
+	callbackQuery.Set("code", code)
+	callbackQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callbackQuery.Encode()
+
+	http.Redirect(writer, request, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token, checking the PKCE verifier
+func token(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, _, ok := request.BasicAuth()
+
+	if !ok {
+		clientID = request.PostForm.Get("client_id")
+	}
+
+	authorizations.Lock()
+	auth := authorizations.byCode[request.PostForm.Get("code")]
+	delete(authorizations.byCode, request.PostForm.Get("code"))
+	authorizations.Unlock()
+
+	challenge := sha256.Sum256([]byte(request.PostForm.Get("code_verifier")))
+
+	if auth == nil || time.Now().After(auth.expiresAt) || auth.clientID != clientID ||
+		auth.redirectURI != request.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            getEnv("MOCK_OIDC_SUBJECT", "mock-user"),
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          auth.nonce,
+		"email":          getEnv("MOCK_OIDC_EMAIL", "staff@example.com"),
+		"email_verified": getEnv("MOCK_OIDC_EMAIL_VERIFIED", "true") == "true",
+		"given_name":     getEnv("MOCK_OIDC_GIVEN_NAME", "Mock"),
+		"family_name":    getEnv("MOCK_OIDC_FAMILY_NAME", "User"),
+		"groups":         strings.Fields(os.Getenv("MOCK_OIDC_GROUPS")),
+		"amr":            strings.Fields(getEnv("MOCK_OIDC_AMR", "pwd")),
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+
+	signedIDToken, err := idToken.SignedString(signingKey)
+
+	if err != nil {
+		writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signedIDToken,
+	})
+}
+
+func jwks(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(signingKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signingKey.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(writer http.ResponseWriter, status int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(body)
+}
+
+func randomString() string {
+	random := make([]byte, 32)
+
+	if _, err := rand.Read(random); err != nil {
+		log.Fatal("Failed to read random bytes: ", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(random)
+}
+
+func getEnv(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
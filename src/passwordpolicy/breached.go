@@ -0,0 +1,112 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedList looks up passwords in a file of SHA-1 hashes, one uppercase hex hash per line, sorted and
+// optionally followed by ":<count>" like the downloads of Have I Been Pwned. Lines may hold a prefix of
+// the hash instead, as long as every line uses the same length. The file is searched on disk, so lists
+// of any size can be used
+type BreachedList struct {
+	file *os.File
+	size int64
+}
+
+func OpenBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	return &BreachedList{file: file, size: info.Size()}, nil
+}
+
+// Contains reports whether the SHA-1 hash of the password is on the list, using a binary search over the file
+func (list *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// only lines starting in [low, high) can still hold the hash
+	low, high := int64(0), list.size
+
+	for low < high {
+		middle := low + (high-low)/2
+
+		start, line, err := list.lineAt(middle)
+		if err != nil {
+			return false, err
+		}
+
+		if start >= high {
+			high = middle
+			continue
+		}
+
+		entry, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		entry = strings.ToUpper(entry)
+		candidate := hash
+
+		if len(entry) < len(candidate) {
+			candidate = candidate[:len(entry)]
+		}
+
+		switch {
+		case entry == candidate && entry != "":
+			return true, nil
+		case entry < candidate:
+			low = start + int64(len(line))
+		default:
+			high = middle
+		}
+	}
+
+	return false, nil
+}
+
+// lineAt returns the first line starting at or after offset together with its start, the line
+// includes its line break. At the end of the file the start is the file size
+func (list *BreachedList) lineAt(offset int64) (int64, string, error) {
+	start := offset
+
+	if offset > 0 {
+		start = offset - 1
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(list.file, start, list.size-start))
+
+	// a line starts right after a line break, so skip the rest of the line offset - 1 is on
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return list.size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+
+	if line == "" {
+		return list.size, "", nil
+	}
+
+	return start, line, nil
+}
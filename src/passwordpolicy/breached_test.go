@@ -0,0 +1,132 @@
+package passwordpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachedList writes the hashes of the passwords, cut to prefixLength when it is set, sorted like the real lists
+func writeBreachedList(t *testing.T, passwords []string, prefixLength int, suffix string, lineBreak string, trailingLineBreak bool) *BreachedList {
+	t.Helper()
+
+	entries := []string{}
+
+	for _, password := range passwords {
+		hash := sha1Hex(password)
+
+		if prefixLength > 0 {
+			hash = hash[:prefixLength]
+		}
+
+		entries = append(entries, hash+suffix)
+	}
+
+	sort.Strings(entries)
+
+	content := strings.Join(entries, lineBreak)
+
+	if trailingLineBreak && len(entries) > 0 {
+		content += lineBreak
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := OpenBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { list.file.Close() })
+
+	return list
+}
+
+func TestBreachedListContains(t *testing.T) {
+	listed := []string{"password", "123456", "letmein", "correct horse battery staple"}
+
+	for i := 0; i < 500; i++ {
+		listed = append(listed, fmt.Sprintf("leaked-%d", i))
+	}
+
+	notListed := []string{"", "Password", "not-leaked", "leaked-500", "leaked--1"}
+
+	tests := []struct {
+		name              string
+		prefixLength      int
+		suffix            string
+		lineBreak         string
+		trailingLineBreak bool
+	}{
+		{name: "full hashes", lineBreak: "\n", trailingLineBreak: true},
+		{name: "without trailing line break", lineBreak: "\n"},
+		{name: "with counts", suffix: ":42", lineBreak: "\n", trailingLineBreak: true},
+		{name: "windows line breaks", suffix: ":7", lineBreak: "\r\n", trailingLineBreak: true},
+		{name: "hash prefixes", prefixLength: 16, lineBreak: "\n", trailingLineBreak: true},
+	}
+
+	for _, test := range tests {
+		list := writeBreachedList(t, listed, test.prefixLength, test.suffix, test.lineBreak, test.trailingLineBreak)
+
+		for _, password := range listed {
+			found, err := list.Contains(password)
+			if err != nil {
+				t.Fatalf("%s: Contains(%q): %v", test.name, password, err)
+			}
+			if !found {
+				t.Errorf("%s: Contains(%q) = false, want true", test.name, password)
+			}
+		}
+
+		for _, password := range notListed {
+			found, err := list.Contains(password)
+			if err != nil {
+				t.Fatalf("%s: Contains(%q): %v", test.name, password, err)
+			}
+			if found {
+				t.Errorf("%s: Contains(%q) = true, want false", test.name, password)
+			}
+		}
+	}
+}
+
+func TestBreachedListContainsWithSmallLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		listed   []string
+		password string
+		want     bool
+	}{
+		{name: "empty list", listed: nil, password: "password", want: false},
+		{name: "single entry found", listed: []string{"password"}, password: "password", want: true},
+		{name: "single entry missing", listed: []string{"password"}, password: "123456", want: false},
+		{name: "first of two", listed: []string{"password", "123456"}, password: "123456", want: true},
+		{name: "second of two", listed: []string{"password", "123456"}, password: "password", want: true},
+	}
+
+	for _, test := range tests {
+		list := writeBreachedList(t, test.listed, 0, "", "\n", true)
+
+		found, err := list.Contains(test.password)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if found != test.want {
+			t.Errorf("%s: Contains(%q) = %v, want %v", test.name, test.password, found, test.want)
+		}
+	}
+}
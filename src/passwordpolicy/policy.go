@@ -0,0 +1,166 @@
+package passwordpolicy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMinLength        = 10
+	defaultMaxLength        = 128
+	defaultMinCharacterKind = 2
+
+	// personal details shorter than this are too common to reject passwords for containing them
+	minPersonalDetailLength = 3
+)
+
+// Policy decides which passwords users may choose, it is configured with PASSWORD_* environment variables
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MinCharacterKinds is how many of lowercase letters, uppercase letters, digits and symbols a password needs
+	MinCharacterKinds int
+	// Breached holds leaked passwords, nil when no list is configured
+	Breached *BreachedList
+}
+
+// NewFromEnv returns the policy configured by PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH,
+// PASSWORD_MIN_CHARACTER_KINDS and PASSWORD_BREACHED_FILE
+func NewFromEnv() (*Policy, error) {
+	policy := &Policy{}
+
+	var err error
+
+	if policy.MinLength, err = envInt("PASSWORD_MIN_LENGTH", defaultMinLength); err != nil {
+		return nil, err
+	}
+	if policy.MaxLength, err = envInt("PASSWORD_MAX_LENGTH", defaultMaxLength); err != nil {
+		return nil, err
+	}
+	if policy.MinCharacterKinds, err = envInt("PASSWORD_MIN_CHARACTER_KINDS", defaultMinCharacterKind); err != nil {
+		return nil, err
+	}
+
+	if policy.MinLength > policy.MaxLength {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH %d is larger than PASSWORD_MAX_LENGTH %d", policy.MinLength, policy.MaxLength)
+	}
+	if policy.MinCharacterKinds > 4 {
+		return nil, fmt.Errorf("PASSWORD_MIN_CHARACTER_KINDS must be at most 4, got %d", policy.MinCharacterKinds)
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_FILE"); path != "" {
+		if policy.Breached, err = OpenBreachedList(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+// Check returns what is wrong with a password, it must not contain any of the personal details
+// of its user like the email address or name. No violations means the password is allowed
+func (policy *Policy) Check(password string, personalDetails ...string) []string {
+	violations := []string{}
+
+	length := utf8.RuneCountInString(password)
+
+	if length < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+	if length > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters long", policy.MaxLength))
+	}
+
+	if characterKinds(password) < policy.MinCharacterKinds {
+		violations = append(violations, fmt.Sprintf("must contain at least %d of lowercase letters, uppercase letters, digits and symbols",
+			policy.MinCharacterKinds))
+	}
+
+	lowerPassword := strings.ToLower(password)
+
+	for _, detail := range personalDetails {
+		if containsPersonalDetail(lowerPassword, detail) {
+			violations = append(violations, "must not contain your email address or name")
+			break
+		}
+	}
+
+	if policy.Breached != nil {
+		breached, err := policy.Breached.Contains(password)
+
+		// a broken list must not stop everyone from choosing a password, the other rules still apply
+		if err != nil {
+			log.Printf("Failed to check the breached password list: %v", err)
+		}
+
+		if breached {
+			violations = append(violations, "is too common or appeared in a data breach, choose another one")
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
+
+// containsPersonalDetail checks the whole detail and, for email addresses, the part before the @
+func containsPersonalDetail(lowerPassword string, detail string) bool {
+	detail = strings.ToLower(strings.TrimSpace(detail))
+	localPart, _, _ := strings.Cut(detail, "@")
+
+	for _, part := range []string{detail, localPart} {
+		if utf8.RuneCountInString(part) >= minPersonalDetailLength && strings.Contains(lowerPassword, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func characterKinds(password string) int {
+	var lower, upper, digit, symbol bool
+
+	for _, character := range password {
+		switch {
+		case unicode.IsLower(character):
+			lower = true
+		case unicode.IsUpper(character):
+			upper = true
+		case unicode.IsDigit(character):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	kinds := 0
+
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			kinds++
+		}
+	}
+
+	return kinds
+}
+
+func envInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s: %v", name, value)
+	}
+
+	return parsed, nil
+}
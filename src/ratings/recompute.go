@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/services"
+)
+
+func init() {
+	config.LoadEnvVariables()
+	config.ConnectToDB()
+}
+
+func main() {
+	recomputed, err := services.RecomputeAllMovieRatings()
+
+	if err != nil {
+		log.Fatalf("Failed to recompute movie ratings after %d movies: %v", recomputed, err)
+	}
+
+	log.Printf("Recomputed ratings for %d movies", recomputed)
+}
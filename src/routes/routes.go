@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jaimy-monsuur/movie-api/src/controllers"
 	"github.com/jaimy-monsuur/movie-api/src/middlewares"
+	"github.com/jaimy-monsuur/movie-api/src/models"
 )
 
 func IndexRoutes(indexRouter *gin.Engine) {
@@ -16,10 +17,29 @@ func UserRoutes(router *gin.Engine) {
 
 	{
 		userRouter.POST("/", controllers.CreateUser)
-		userRouter.GET("/", middlewares.Auth(), controllers.GetAllUsers)
+		userRouter.GET("/", middlewares.RequirePermission(models.PermissionUsersRead), controllers.GetAllUsers)
 		userRouter.GET("/:id", middlewares.Auth(), controllers.GetUserByID)
-		userRouter.PUT("/:id", middlewares.Auth(), controllers.UpdateUser)
-		userRouter.DELETE("/:id", middlewares.AdminAuth(), controllers.DeleteUser)
+		userRouter.PUT("/:id", middlewares.Auth(), middlewares.RequireLogin(), controllers.UpdateUser)
+		userRouter.PUT("/:id/password", middlewares.Auth(), middlewares.RequireLogin(), controllers.ChangePassword)
+		userRouter.POST("/me/mfa/totp", middlewares.Auth(), middlewares.RequireLogin(), controllers.EnrollTotp)
+		userRouter.POST("/me/mfa/totp/confirm", middlewares.Auth(), middlewares.RequireLogin(), controllers.ConfirmTotp)
+		userRouter.DELETE("/me/mfa/totp", middlewares.Auth(), middlewares.RequireLogin(), controllers.DisableTotp)
+		userRouter.POST("/me/mfa/recovery-codes", middlewares.Auth(), middlewares.RequireLogin(), controllers.RegenerateRecoveryCodes)
+		userRouter.POST("/me/tokens", middlewares.Auth(), middlewares.RequireLogin(), controllers.CreatePersonalAccessToken)
+		userRouter.GET("/me/tokens", middlewares.Auth(), middlewares.RequireLogin(), controllers.GetPersonalAccessTokens)
+		userRouter.DELETE("/me/tokens/:id", middlewares.Auth(), middlewares.RequireLogin(), controllers.RevokePersonalAccessToken)
+		userRouter.GET("/me/sessions", middlewares.Auth(), middlewares.RequireLogin(), controllers.GetSessions)
+		userRouter.DELETE("/me/sessions/:id", middlewares.Auth(), middlewares.RequireLogin(), controllers.RevokeSession)
+		userRouter.POST("/me/passkeys/options", middlewares.Auth(), middlewares.RequireLogin(), controllers.StartPasskeyRegistration)
+		userRouter.POST("/me/passkeys", middlewares.Auth(), middlewares.RequireLogin(), controllers.RegisterPasskey)
+		userRouter.GET("/me/passkeys", middlewares.Auth(), middlewares.RequireLogin(), controllers.GetPasskeys)
+		userRouter.DELETE("/me/passkeys/:id", middlewares.Auth(), middlewares.RequireLogin(), controllers.DeletePasskey)
+		userRouter.PUT("/:id/roles", middlewares.RequirePermission(models.PermissionRolesManage), controllers.UpdateUserRoles)
+		userRouter.POST("/:id/unlock", middlewares.RequirePermission(models.PermissionUsersWrite), controllers.UnlockUser)
+		userRouter.POST("/:id/disable", middlewares.RequirePermission(models.PermissionUsersWrite), controllers.DisableUser)
+		userRouter.POST("/:id/enable", middlewares.RequirePermission(models.PermissionUsersWrite), controllers.EnableUser)
+		userRouter.POST("/:id/password-reset", middlewares.RequirePermission(models.PermissionUsersWrite), controllers.ForcePasswordReset)
+		userRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionUsersDelete), controllers.DeleteUser)
 	}
 }
 
@@ -29,30 +49,140 @@ func AuthRoutes(router *gin.Engine) {
 
 	{
 		authRouter.POST("/login", controllers.LoginUser)
+		authRouter.POST("/mfa/verify", controllers.VerifyMfa)
+		authRouter.POST("/mfa/enroll", controllers.EnrollMfaWithChallenge)
+		authRouter.POST("/magic-link", controllers.RequestMagicLink)
+		authRouter.GET("/magic-link/callback", controllers.MagicLinkCallback)
+		authRouter.POST("/passkey/options", controllers.StartPasskeyLogin)
+		authRouter.POST("/passkey/verify", controllers.FinishPasskeyLogin)
+		authRouter.GET("/oidc/:provider/start", controllers.StartOidcLogin)
+		authRouter.GET("/oidc/:provider/callback", controllers.OidcCallback)
+		authRouter.POST("/refresh", controllers.RefreshToken)
+		authRouter.POST("/logout", middlewares.Auth(), controllers.Logout)
+		authRouter.POST("/logout-all", middlewares.Auth(), middlewares.RequireLogin(), controllers.LogoutAll)
+		authRouter.POST("/verify-email", controllers.VerifyEmail)
+		authRouter.POST("/resend-verification", controllers.ResendVerificationEmail)
+		authRouter.POST("/forgot-password", controllers.ForgotPassword)
+		authRouter.POST("/reset-password", controllers.ResetPassword)
+		authRouter.GET("/lockouts", middlewares.RequirePermission(models.PermissionAuditRead), controllers.GetLockouts)
+		authRouter.DELETE("/lockouts/:id", middlewares.RequirePermission(models.PermissionUsersWrite), controllers.ClearLockout)
 	}
 }
 
+func RoleRoutes(router *gin.Engine) {
+
+	roleRouter := router.Group("/roles")
+
+	{
+		roleRouter.GET("/", middlewares.RequirePermission(models.PermissionRolesManage), controllers.GetAllRoles)
+		roleRouter.POST("/", middlewares.RequirePermission(models.PermissionRolesManage), controllers.CreateRole)
+		roleRouter.PUT("/:id", middlewares.RequirePermission(models.PermissionRolesManage), controllers.UpdateRole)
+		roleRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionRolesManage), controllers.DeleteRole)
+	}
+
+	router.GET("/permissions", middlewares.RequirePermission(models.PermissionRolesManage), controllers.GetAllPermissions)
+}
+
+func AuditRoutes(router *gin.Engine) {
+	router.GET("/audit-logs", middlewares.RequirePermission(models.PermissionAuditRead), controllers.GetAuditLogs)
+}
+
+func OAuthRoutes(router *gin.Engine) {
+
+	oauthRouter := router.Group("/oauth")
+
+	{
+		oauthRouter.GET("/authorize", controllers.Authorize)
+		oauthRouter.GET("/consent", middlewares.Auth(), middlewares.RequireLogin(), controllers.GetConsent)
+		oauthRouter.POST("/consent", middlewares.Auth(), middlewares.RequireLogin(), controllers.DecideConsent)
+		oauthRouter.POST("/token", controllers.ExchangeOAuthToken)
+		oauthRouter.POST("/introspect", controllers.IntrospectOAuthToken)
+		oauthRouter.POST("/revoke", controllers.RevokeOAuthToken)
+		oauthRouter.POST("/clients", middlewares.Auth(), middlewares.RequireLogin(), controllers.CreateOAuthClient)
+		oauthRouter.GET("/clients", middlewares.Auth(), middlewares.RequireLogin(), controllers.GetOAuthClients)
+		oauthRouter.DELETE("/clients/:id", middlewares.Auth(), middlewares.RequireLogin(), controllers.DeleteOAuthClient)
+	}
+}
+
+func WellKnownRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", controllers.GetJwks)
+}
+
 func MovieRoutes(router *gin.Engine) {
 
 	movieRouter := router.Group("/movies")
 
 	{
-		movieRouter.POST("/", middlewares.AdminAuth(), controllers.CreateMovie)
-		movieRouter.GET("/", middlewares.Auth(), controllers.GetAllMovies)
-		movieRouter.GET("/:id", middlewares.Auth(), controllers.GetMovieByID)
-		movieRouter.PUT("/:id", middlewares.AdminAuth(), controllers.UpdateMovie)
-		movieRouter.DELETE("/:id", middlewares.AdminAuth(), controllers.DeleteMovie)
+		movieRouter.POST("/", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.CreateMovie)
+		movieRouter.GET("/", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetAllMovies)
+		movieRouter.GET("/search", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.SearchMovies)
+		movieRouter.GET("/facets", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetMovieFacets)
+		movieRouter.GET("/:id", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetMovieByID)
+		movieRouter.PUT("/:id", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.UpdateMovie)
+		movieRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionMoviesDelete), controllers.DeleteMovie)
+		movieRouter.POST("/:id/ratings/recompute", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.RecomputeMovieRating)
+		movieRouter.PUT("/:id/my-review", middlewares.RequirePermission(models.PermissionReviewsWrite), middlewares.RequireVerifiedEmail(), controllers.UpsertMyReview)
+		movieRouter.GET("/:id/credits", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetMovieCredits)
+		movieRouter.POST("/:id/credits", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.CreateCredit)
+		movieRouter.DELETE("/:id/credits/:creditId", middlewares.RequirePermission(models.PermissionMoviesDelete), controllers.DeleteCredit)
 	}
 }
 
+func GenreRoutes(router *gin.Engine) {
+
+	genreRouter := router.Group("/genres")
+
+	{
+		genreRouter.GET("/", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetAllGenres)
+		genreRouter.POST("/", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.CreateGenre)
+		genreRouter.PUT("/:id", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.UpdateGenre)
+		genreRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionMoviesDelete), controllers.DeleteGenre)
+	}
+}
+
+func KeywordRoutes(router *gin.Engine) {
+
+	keywordRouter := router.Group("/keywords")
+
+	{
+		keywordRouter.GET("/", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetAllKeywords)
+		keywordRouter.POST("/", middlewares.RequirePermission(models.PermissionMoviesWrite), controllers.CreateKeyword)
+		keywordRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionMoviesDelete), controllers.DeleteKeyword)
+	}
+}
+
+func PeopleRoutes(router *gin.Engine) {
+
+	peopleRouter := router.Group("/people")
+
+	{
+		peopleRouter.GET("/", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetAllPeople)
+		peopleRouter.GET("/:id", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetPersonByID)
+		peopleRouter.GET("/:id/filmography", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.GetFilmography)
+	}
+}
+
+func AutocompleteRoutes(router *gin.Engine) {
+	router.GET("/autocomplete", middlewares.RequirePermission(models.PermissionMoviesRead), controllers.Autocomplete)
+}
+
 func ReviewRoutes(router *gin.Engine) {
 
 	reviewRouter := router.Group("/reviews")
 
 	{
-		reviewRouter.POST("/", middlewares.Auth(), controllers.CreateReview)
-		reviewRouter.PUT("/:id", middlewares.Auth(), controllers.UpdateReview)
-		reviewRouter.DELETE("/:id", middlewares.AdminAuth(), controllers.DeleteReview)
-		reviewRouter.GET("/:id", middlewares.Auth(), controllers.GetReviewByMovieId)
+		reviewRouter.POST("/", middlewares.RequirePermission(models.PermissionReviewsWrite), middlewares.RequireVerifiedEmail(), controllers.CreateReview)
+		reviewRouter.PUT("/:id", middlewares.RequirePermission(models.PermissionReviewsWrite), middlewares.RequireVerifiedEmail(), controllers.UpdateReview)
+		reviewRouter.DELETE("/:id", middlewares.RequirePermission(models.PermissionReviewsModerate), controllers.DeleteReview)
+		reviewRouter.GET("/:id", middlewares.RequirePermission(models.PermissionReviewsRead), controllers.GetReviewByMovieId)
+	}
+}
+
+func ReviewRoutesV2(router *gin.Engine) {
+
+	reviewRouter := router.Group("/v2/reviews")
+
+	{
+		reviewRouter.POST("/", middlewares.RequirePermission(models.PermissionReviewsWrite), middlewares.RequireVerifiedEmail(), controllers.CreateReviewV2)
 	}
 }
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const defaultAuditLogPageLimit = 50
+
+// recordAudit stores an audit log entry, errors are only logged so auditing never fails the request
+func recordAudit(entry *models.AuditLog) {
+	if err := config.DB.Create(entry).Error; err != nil {
+		log.Printf("Failed to record audit event %s: %v", entry.Event, err)
+	}
+}
+
+// GetAuditLogs returns the audit log, newest first
+func GetAuditLogs(query dtos.AuditLogQueryDto) ([]*dtos.AuditLogDto, *dtos.PaginationDto, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultAuditLogPageLimit
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	var total int64
+
+	if err := filterAuditLogs(config.DB.Model(&models.AuditLog{}), query).Count(&total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var entries []*models.AuditLog
+
+	err := filterAuditLogs(config.DB, query).Order("created_at DESC").Order("id").Limit(query.Limit).Offset((query.Page - 1) * query.Limit).Find(&entries).Error
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	returnEntries := []*dtos.AuditLogDto{}
+
+	for _, entry := range entries {
+		entryDto := &dtos.AuditLogDto{
+			ID:        entry.ID.String(),
+			Event:     entry.Event,
+			Email:     entry.Email,
+			IPAddress: entry.IPAddress,
+			Detail:    entry.Detail,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.UserID != nil {
+			entryDto.UserID = entry.UserID.String()
+		}
+		if entry.ActorID != nil {
+			entryDto.ActorID = entry.ActorID.String()
+		}
+		returnEntries = append(returnEntries, entryDto)
+	}
+
+	pagination := &dtos.PaginationDto{
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+
+	return returnEntries, pagination, nil
+}
+
+func filterAuditLogs(db *gorm.DB, query dtos.AuditLogQueryDto) *gorm.DB {
+	if query.Event != "" {
+		db = db.Where("event = ?", query.Event)
+	}
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Email != "" {
+		db = db.Where("email = ?", normalizeEmail(query.Email))
+	}
+	if query.IPAddress != "" {
+		db = db.Where("ip_address = ?", query.IPAddress)
+	}
+
+	return db
+}
@@ -3,40 +3,81 @@ package services
 import (
 	"errors"
 	"fmt"
-	"github.com/gin-gonic/gin"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
 )
 
 type JwtClaims struct {
-	Email string `json:"email"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+	Scope         string   `json:"scope"`
+	TokenVersion  int      `json:"ver"`
+	SessionID     string   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+const principalContextKey = "principal"
+
+// token versions are cached so authenticating a request does not need the database,
+// a bumped version is picked up by other instances within tokenVersionCacheTTL
+const tokenVersionCacheTTL = 30 * time.Second
+
+type cachedTokenVersion struct {
+	version   int
+	expiresAt time.Time
+}
+
+var tokenVersionCache sync.Map
 
-func GenerateJwt(userID uuid.UUID) (tokenString string, err error) {
+// GenerateJwt returns an access token of the user for the session it logged in with
+func GenerateJwt(user *models.User, sessionID uuid.UUID) (tokenString string, err error) {
+
+	// the roles are loaded fresh, so a token never carries permissions the user lost
+	roles, err := loadUserRoles(config.DB, user.ID)
+
+	if err != nil {
+		return "", err
+	}
 
 	// Create the Claims
 	claims := JwtClaims{
-		"someone@example.com", // This value specified on puepose, to be changed later
+		user.Email,
+		user.EmailVerifiedAt != nil,
+		roleNames(roles),
+		strings.Join(rolePermissionNames(roles), " "),
+		user.TokenVersion,
+		sessionID.String(),
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "movie-api",
-			Subject:   userID.String(),
+			Subject:   user.ID.String(),
 			Audience:  []string{"movie-api"},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key, err := currentSigningKey()
 
-	tokenString, err = token.SignedString(jwtSecret)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+
+	tokenString, err = token.SignedString(key.privateKey)
 
 	return
 }
@@ -46,11 +87,19 @@ func GetTokenClaims(signedToken string) (claims *JwtClaims, err error) {
 	token, err := jwt.ParseWithClaims(signedToken, &JwtClaims{},
 		func(token *jwt.Token) (interface{}, error) {
 
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			kid, _ := token.Header["kid"].(string)
+
+			key, err := verificationKey(kid)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if token.Method.Alg() != key.method.Alg() {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 
-			return jwtSecret, nil
+			return key.privateKey.Public(), nil
 		},
 	)
 
@@ -67,90 +116,163 @@ func GetTokenClaims(signedToken string) (claims *JwtClaims, err error) {
 
 }
 
-func ValidateToken(signedToken string) (err error) {
+// ValidateToken checks the signature and expiry of a token, that neither it nor its session was revoked
+// and that it was issued after the last token version bump of its user
+func ValidateToken(signedToken string) (claims *JwtClaims, err error) {
 
-	token, err := jwt.ParseWithClaims(signedToken, &JwtClaims{},
-		func(token *jwt.Token) (interface{}, error) {
+	claims, err = GetTokenClaims(signedToken)
 
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
+	if err != nil {
+		return nil, err
+	}
 
-			return jwtSecret, nil
-		},
-	)
+	revoked, err := isTokenRevoked(claims.ID)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid {
-		fmt.Printf("%v %v", claims.Email, claims.RegisteredClaims.Issuer)
-		return nil
-	} else {
-		err = errors.New("token expired")
-		return
+	if revoked {
+		return nil, errors.New("token has been revoked")
 	}
 
+	version, err := currentTokenVersion(claims.Subject)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenVersion != version {
+		return nil, errors.New("token has been invalidated")
+	}
+
+	// tokens issued before sessions existed carry no session
+	if claims.SessionID != "" {
+		active, err := isSessionActive(claims.SessionID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !active {
+			return nil, errors.New("session has been revoked")
+		}
+	}
+
+	return claims, nil
 }
 
-func ValidateAdminToken(signedToken string) (err error) {
+// AuthenticateToken validates a token and returns the principal described by its claims
+func AuthenticateToken(signedToken string) (*interfaces.Principal, error) {
 
-	token, err := jwt.ParseWithClaims(signedToken, &JwtClaims{},
-		func(token *jwt.Token) (interface{}, error) {
+	if strings.HasPrefix(signedToken, personalAccessTokenPrefix) {
+		return authenticatePersonalAccessToken(signedToken)
+	}
 
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
+	if strings.HasPrefix(signedToken, oauthAccessTokenPrefix) {
+		return authenticateOAuthToken(signedToken)
+	}
 
-			return jwtSecret, nil
-		},
-	)
+	claims, err := ValidateToken(signedToken)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid {
-		if isAdmin(claims) {
-			fmt.Printf("%v %v", claims.Email, claims.RegisteredClaims.Issuer)
-			return nil
+	userID, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt == nil || claims.ID == "" {
+		return nil, errors.New("token has no expiry or id")
+	}
+
+	var sessionID uuid.UUID
+
+	if claims.SessionID != "" {
+		if sessionID, err = uuid.Parse(claims.SessionID); err != nil {
+			return nil, err
 		}
-		err = errors.New("not an admin")
-		return
+	}
 
-	} else {
-		err = errors.New("token expired")
-		return
+	return &interfaces.Principal{
+		UserID:         userID,
+		Email:          claims.Email,
+		EmailVerified:  claims.EmailVerified,
+		Roles:          claims.Roles,
+		Scopes:         strings.Fields(claims.Scope),
+		TokenID:        claims.ID,
+		TokenExpiresAt: claims.ExpiresAt.Time,
+		SessionID:      sessionID,
+	}, nil
+}
+
+// InvalidateUserTokens bumps the token version of a user, every token issued before is rejected once the
+// transaction commits. Callers forget the cached version with forgetTokenVersions after the commit
+func InvalidateUserTokens(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// forgetTokenVersions drops cached token versions, so bumped versions are picked up right away. It only runs
+// after the bump is committed, otherwise a request in between would cache the old version again
+func forgetTokenVersions(userIDs ...uuid.UUID) {
+	for _, userID := range userIDs {
+		tokenVersionCache.Delete(userID.String())
+	}
+}
+
+func currentTokenVersion(userID string) (int, error) {
+
+	if cached, ok := tokenVersionCache.Load(userID); ok {
+		if entry := cached.(cachedTokenVersion); time.Now().Before(entry.expiresAt) {
+			return entry.version, nil
+		}
+	}
+
+	var user models.User
+
+	if err := config.DB.Select("token_version").First(&user, "id = ?", userID).Error; err != nil {
+		return 0, errors.New("user from token no longer exists")
+	}
+
+	tokenVersionCache.Store(userID, cachedTokenVersion{
+		version:   user.TokenVersion,
+		expiresAt: time.Now().Add(tokenVersionCacheTTL),
+	})
+
+	return user.TokenVersion, nil
+}
+
+func SetPrincipal(context *gin.Context, principal *interfaces.Principal) {
+	context.Set(principalContextKey, principal)
+}
+
+// GetPrincipal returns the authenticated caller, only available on routes behind the auth middleware
+func GetPrincipal(context *gin.Context) (*interfaces.Principal, error) {
+
+	if principal, ok := context.Get(principalContextKey); ok {
+		return principal.(*interfaces.Principal), nil
 	}
 
+	return nil, errors.New("request is not authenticated")
 }
 
 func CheckUser(context *gin.Context, userID string) error {
 
-	//get user from token
-	bearerToken := context.GetHeader("Authorization")
-	accessToken := strings.Split(bearerToken, "Bearer ")[1]
-	claims, err := GetTokenClaims(accessToken)
+	principal, err := GetPrincipal(context)
 
 	if err != nil {
 		return err
 	}
 
-	if claims.Subject != userID {
+	if principal.UserID.String() != userID {
 		return errors.New("user from token is not the same as the user from the request")
 	}
 
 	return nil
 
 }
-
-func isAdmin(claims *JwtClaims) bool {
-
-	var user, _ = GetUserByID(claims.Subject)
-	print(user.Role)
-	if user.Role == "admin" {
-		return true
-	}
-	return false
-}
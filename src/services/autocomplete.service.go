@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSuggestionLimit = 10
+
+	// autocomplete is called on every keystroke, queries running longer than this are cancelled
+	autocompleteTimeoutMs = 200
+
+	// minimum word similarity of a match, lower than the pg_trgm default of 0.6 to tolerate typos
+	autocompleteSimilarityThreshold = 0.4
+)
+
+const autocompleteQuery = `WITH search AS (SELECT immutable_unaccent(lower(@term)) AS term)
+(SELECT 'movie' AS type, movies.id::text AS id, movies.title AS label,
+		word_similarity(search.term, immutable_unaccent(lower(movies.title))) AS score
+	FROM movies, search
+	WHERE search.term <% immutable_unaccent(lower(movies.title))
+	ORDER BY score DESC
+	LIMIT @limit)
+UNION ALL
+(SELECT 'director', NULL, movies.director,
+		max(word_similarity(search.term, immutable_unaccent(lower(movies.director)))) AS score
+	FROM movies, search
+	WHERE search.term <% immutable_unaccent(lower(movies.director))
+	GROUP BY movies.director
+	ORDER BY score DESC
+	LIMIT @limit)
+UNION ALL
+(SELECT 'actor', NULL, trim(actor_name),
+		max(word_similarity(search.term, immutable_unaccent(lower(trim(actor_name))))) AS score
+	FROM movies, search, unnest(string_to_array(movies.actors, ',')) AS actor_name
+	WHERE search.term <% immutable_unaccent(lower(movies.actors))
+		AND word_similarity(search.term, immutable_unaccent(lower(trim(actor_name)))) >= @threshold
+	GROUP BY trim(actor_name)
+	ORDER BY score DESC
+	LIMIT @limit)
+ORDER BY score DESC, label
+LIMIT @limit`
+
+// Autocomplete returns the best fuzzy matches for q over movie titles, directors and actors.
+// When the time budget is exceeded no suggestions are returned instead of an error,
+// the next keystroke will simply ask again
+func Autocomplete(query dtos.AutocompleteQueryDto) ([]*dtos.SuggestionDto, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultSuggestionLimit
+	}
+
+	suggestions := []*dtos.SuggestionDto{}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", autocompleteTimeoutMs)).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %g", autocompleteSimilarityThreshold)).Error; err != nil {
+			return err
+		}
+
+		return tx.Raw(autocompleteQuery, map[string]interface{}{
+			"term":      query.Q,
+			"limit":     query.Limit,
+			"threshold": autocompleteSimilarityThreshold,
+		}).Scan(&suggestions).Error
+	})
+
+	if isQueryCanceled(err) {
+		return []*dtos.SuggestionDto{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+func GetMovieCredits(movieID string) (*dtos.MovieCreditsDto, error) {
+	if _, err := GetMovieById(movieID); err != nil {
+		return nil, err
+	}
+
+	var credits []*models.Credit
+
+	err := config.DB.Joins("Person").
+		Where("credits.movie_id = ?", movieID).
+		Order("credits.billing_order").
+		Order(`"Person".name`).
+		Find(&credits).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	movieCredits := &dtos.MovieCreditsDto{
+		Cast: []*dtos.CreditDto{},
+		Crew: []*dtos.CreditDto{},
+	}
+
+	for _, credit := range credits {
+		if credit.RoleType == models.CreditRoleCast {
+			movieCredits.Cast = append(movieCredits.Cast, creditToDto(credit))
+		} else {
+			movieCredits.Crew = append(movieCredits.Crew, creditToDto(credit))
+		}
+	}
+
+	return movieCredits, nil
+}
+
+func CreateCredit(movieID string, createCreditDto dtos.CreateCreditDto) (*dtos.CreditDto, *interfaces.ServiceError) {
+	movie, err := GetMovieById(movieID)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	credit := models.Credit{
+		MovieID:      movie.ID,
+		RoleType:     createCreditDto.RoleType,
+		Character:    createCreditDto.Character,
+		BillingOrder: createCreditDto.BillingOrder,
+		Department:   createCreditDto.Department,
+		Job:          createCreditDto.Job,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var person *models.Person
+
+		if createCreditDto.PersonID != "" {
+			person = &models.Person{}
+
+			if err := tx.First(person, "id = ?", createCreditDto.PersonID).Error; err != nil {
+				return err
+			}
+		} else {
+			person, err = findOrCreatePerson(tx, strings.TrimSpace(createCreditDto.PersonName))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		credit.PersonID = person.ID
+		credit.Person = *person
+
+		if credit.RoleType == models.CreditRoleCast && credit.BillingOrder == 0 {
+			err := tx.Model(&models.Credit{}).
+				Select("COALESCE(MAX(billing_order), 0) + 1").
+				Where("movie_id = ? AND role_type = ?", movie.ID, models.CreditRoleCast).
+				Scan(&credit.BillingOrder).Error
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Omit("Movie", "Person").Create(&credit).Error; err != nil {
+			return err
+		}
+
+		return syncLegacyFieldsFromCredits(tx, movie.ID)
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("person with ID: " + createCreditDto.PersonID + " not found"),
+			StatusCode: 404,
+		}
+	}
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	return creditToDto(&credit), nil
+}
+
+func DeleteCredit(movieID string, creditID string) *interfaces.ServiceError {
+	var credit models.Credit
+
+	if err := config.DB.First(&credit, "id = ? AND movie_id = ?", creditID, movieID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&credit).Error; err != nil {
+			return err
+		}
+
+		return syncLegacyFieldsFromCredits(tx, credit.MovieID)
+	})
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	return nil
+}
+
+// BackfillCredits creates the credits of every movie that has director or actors but no credits yet
+func BackfillCredits() (int, error) {
+	var movies []*models.Movie
+
+	err := config.DB.
+		Where("NOT EXISTS (SELECT 1 FROM credits WHERE credits.movie_id = movies.id)").
+		Where("director <> '' OR actors <> ''").
+		Find(&movies).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	backfilled := 0
+
+	for _, movie := range movies {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return syncCreditsFromLegacyFields(tx, movie)
+		})
+
+		if err != nil {
+			return backfilled, err
+		}
+
+		backfilled++
+	}
+
+	return backfilled, nil
+}
+
+// syncCreditsFromLegacyFields makes the cast and director credits of a movie match its comma separated
+// Actors and Director fields. Credits of people that are still listed are kept so their character
+// names survive, only the billing order follows the new position in the list
+func syncCreditsFromLegacyFields(tx *gorm.DB, movie *models.Movie) error {
+	var credits []*models.Credit
+
+	err := tx.Joins("Person").
+		Where("credits.movie_id = ?", movie.ID).
+		Where("credits.role_type = ? OR (credits.role_type = ? AND credits.job = ?)", models.CreditRoleCast, models.CreditRoleCrew, models.DirectorJob).
+		Find(&credits).Error
+
+	if err != nil {
+		return err
+	}
+
+	existingCast := map[string]*models.Credit{}
+	existingDirectors := map[string]*models.Credit{}
+
+	for _, credit := range credits {
+		if credit.RoleType == models.CreditRoleCast {
+			existingCast[credit.Person.Name] = credit
+		} else {
+			existingDirectors[credit.Person.Name] = credit
+		}
+	}
+
+	for i, name := range splitNames(movie.Actors) {
+		credit, exists := existingCast[name]
+
+		if !exists {
+			credit = &models.Credit{MovieID: movie.ID, RoleType: models.CreditRoleCast}
+		}
+		delete(existingCast, name)
+
+		credit.BillingOrder = i + 1
+
+		if err := saveCredit(tx, credit, name); err != nil {
+			return err
+		}
+	}
+
+	for i, name := range splitNames(movie.Director) {
+		credit, exists := existingDirectors[name]
+
+		if !exists {
+			credit = &models.Credit{
+				MovieID:    movie.ID,
+				RoleType:   models.CreditRoleCrew,
+				Department: models.DirectingDepartment,
+				Job:        models.DirectorJob,
+			}
+		}
+		delete(existingDirectors, name)
+
+		credit.BillingOrder = i + 1
+
+		if err := saveCredit(tx, credit, name); err != nil {
+			return err
+		}
+	}
+
+	var removedCreditIDs []uuid.UUID
+
+	for _, credit := range existingCast {
+		removedCreditIDs = append(removedCreditIDs, credit.ID)
+	}
+	for _, credit := range existingDirectors {
+		removedCreditIDs = append(removedCreditIDs, credit.ID)
+	}
+
+	if len(removedCreditIDs) == 0 {
+		return nil
+	}
+
+	return tx.Delete(&models.Credit{}, "id IN ?", removedCreditIDs).Error
+}
+
+// syncLegacyFieldsFromCredits rewrites the comma separated Actors and Director fields of a movie from its credits
+func syncLegacyFieldsFromCredits(tx *gorm.DB, movieID uuid.UUID) error {
+	var credits []*models.Credit
+
+	err := tx.Joins("Person").
+		Where("credits.movie_id = ?", movieID).
+		Where("credits.role_type = ? OR (credits.role_type = ? AND credits.job = ?)", models.CreditRoleCast, models.CreditRoleCrew, models.DirectorJob).
+		Order("credits.billing_order").
+		Order("credits.created_at").
+		Find(&credits).Error
+
+	if err != nil {
+		return err
+	}
+
+	var actors []string
+	var directors []string
+
+	for _, credit := range credits {
+		if credit.RoleType == models.CreditRoleCast {
+			actors = append(actors, credit.Person.Name)
+		} else {
+			directors = append(directors, credit.Person.Name)
+		}
+	}
+
+	return tx.Model(&models.Movie{}).Where("id = ?", movieID).Updates(map[string]interface{}{
+		"actors":   strings.Join(actors, ", "),
+		"director": strings.Join(directors, ", "),
+	}).Error
+}
+
+func saveCredit(tx *gorm.DB, credit *models.Credit, name string) error {
+	if credit.PersonID == uuid.Nil {
+		person, err := findOrCreatePerson(tx, name)
+
+		if err != nil {
+			return err
+		}
+
+		credit.PersonID = person.ID
+	}
+
+	return tx.Omit("Movie", "Person").Save(credit).Error
+}
+
+// splitNames splits a comma separated list of names, ignoring blank entries and duplicates
+func splitNames(names string) []string {
+	var result []string
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		result = append(result, name)
+	}
+
+	return result
+}
+
+func creditToDto(credit *models.Credit) *dtos.CreditDto {
+	return &dtos.CreditDto{
+		ID:           credit.ID.String(),
+		PersonID:     credit.PersonID.String(),
+		Name:         credit.Person.Name,
+		RoleType:     credit.RoleType,
+		Character:    credit.Character,
+		BillingOrder: credit.BillingOrder,
+		Department:   credit.Department,
+		Job:          credit.Job,
+	}
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+const (
+	uniqueViolationCode = "23505"
+	queryCanceledCode   = "57014"
+)
+
+// isUniqueViolation reports whether err was caused by a unique constraint of the database
+func isUniqueViolation(err error) bool {
+	var pgError *pgconn.PgError
+
+	return errors.As(err, &pgError) && pgError.Code == uniqueViolationCode
+}
+
+// isQueryCanceled reports whether err was caused by the query being cancelled, e.g. by a statement timeout
+func isQueryCanceled(err error) bool {
+	var pgError *pgconn.PgError
+
+	return errors.As(err, &pgError) && pgError.Code == queryCanceledCode
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+)
+
+func GetAllGenres() ([]*dtos.GenreDto, error) {
+	var genres []*models.Genre
+
+	if err := config.DB.Order("name").Find(&genres).Error; err != nil {
+		return nil, err
+	}
+
+	returnGenres := []*dtos.GenreDto{}
+
+	for _, genre := range genres {
+		returnGenres = append(returnGenres, &dtos.GenreDto{
+			ID:   genre.ID.String(),
+			Name: genre.Name,
+		})
+	}
+
+	return returnGenres, nil
+}
+
+func CreateGenre(createGenreDto dtos.CreateGenreDto) (*dtos.GenreDto, *interfaces.ServiceError) {
+	name := strings.TrimSpace(createGenreDto.Name)
+
+	var genreExists models.Genre
+
+	if err := config.DB.First(&genreExists, "LOWER(name) = LOWER(?)", name).Error; err == nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("Genre with name: " + name + " already exists"),
+			StatusCode: 409,
+		}
+	}
+
+	newGenre := models.Genre{Name: name}
+
+	if err := config.DB.Create(&newGenre).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	return &dtos.GenreDto{
+		ID:   newGenre.ID.String(),
+		Name: newGenre.Name,
+	}, nil
+}
+
+func UpdateGenre(ID string, updateGenreDto dtos.UpdateGenreDto) (*dtos.GenreDto, *interfaces.ServiceError) {
+	var genre models.Genre
+
+	if err := config.DB.First(&genre, "id = ?", ID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	name := strings.TrimSpace(updateGenreDto.Name)
+
+	var genreExists models.Genre
+
+	if err := config.DB.First(&genreExists, "LOWER(name) = LOWER(?) AND id <> ?", name, genre.ID).Error; err == nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("Genre with name: " + name + " already exists"),
+			StatusCode: 409,
+		}
+	}
+
+	if err := config.DB.Model(&genre).Update("name", name).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	return &dtos.GenreDto{
+		ID:   genre.ID.String(),
+		Name: genre.Name,
+	}, nil
+}
+
+func DeleteGenre(ID string) *interfaces.ServiceError {
+	result := config.DB.Delete(&models.Genre{}, "id = ?", ID)
+
+	if result.Error != nil {
+		return &interfaces.ServiceError{
+			Error:      result.Error,
+			StatusCode: 400,
+		}
+	}
+
+	if result.RowsAffected == 0 {
+		return &interfaces.ServiceError{
+			Error:      errors.New("Genre with ID: " + ID + " not found"),
+			StatusCode: 404,
+		}
+	}
+
+	return nil
+}
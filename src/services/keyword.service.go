@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+func GetAllKeywords() ([]*dtos.KeywordDto, error) {
+	var keywords []*models.Keyword
+
+	if err := config.DB.Order("name").Find(&keywords).Error; err != nil {
+		return nil, err
+	}
+
+	returnKeywords := []*dtos.KeywordDto{}
+
+	for _, keyword := range keywords {
+		returnKeywords = append(returnKeywords, &dtos.KeywordDto{
+			ID:   keyword.ID.String(),
+			Name: keyword.Name,
+		})
+	}
+
+	return returnKeywords, nil
+}
+
+func CreateKeyword(createKeywordDto dtos.CreateKeywordDto) (*dtos.KeywordDto, *interfaces.ServiceError) {
+	name := normalizeKeyword(createKeywordDto.Name)
+
+	var keywordExists models.Keyword
+
+	if err := config.DB.First(&keywordExists, "name = ?", name).Error; err == nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("Keyword: " + name + " already exists"),
+			StatusCode: 409,
+		}
+	}
+
+	newKeyword := models.Keyword{Name: name}
+
+	if err := config.DB.Create(&newKeyword).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	return &dtos.KeywordDto{
+		ID:   newKeyword.ID.String(),
+		Name: newKeyword.Name,
+	}, nil
+}
+
+func DeleteKeyword(ID string) *interfaces.ServiceError {
+	result := config.DB.Delete(&models.Keyword{}, "id = ?", ID)
+
+	if result.Error != nil {
+		return &interfaces.ServiceError{
+			Error:      result.Error,
+			StatusCode: 400,
+		}
+	}
+
+	if result.RowsAffected == 0 {
+		return &interfaces.ServiceError{
+			Error:      errors.New("Keyword with ID: " + ID + " not found"),
+			StatusCode: 404,
+		}
+	}
+
+	return nil
+}
+
+// findOrCreateKeywords returns the keywords with the given names, creating the ones that do not exist yet
+func findOrCreateKeywords(tx *gorm.DB, names []string) ([]models.Keyword, error) {
+	keywords := []models.Keyword{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		name = normalizeKeyword(name)
+
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var keyword models.Keyword
+
+		if err := tx.Where(models.Keyword{Name: name}).FirstOrCreate(&keyword).Error; err != nil {
+			return nil, err
+		}
+
+		keywords = append(keywords, keyword)
+	}
+
+	return keywords, nil
+}
+
+// keywords are free form tags, they are stored lower case so "Heist" and "heist" are the same keyword
+func normalizeKeyword(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxLoginBackoff = 5 * time.Minute
+
+// failed logins that are allowed before every further attempt has to wait, an IP address
+// gets more room because many users can share one
+var loginFreeAttempts = map[string]int{
+	models.LoginThrottleAccount: 3,
+	models.LoginThrottleIP:      20,
+}
+
+// LoginAttempt is a login attempt that was counted as failed before the credentials were checked, so parallel
+// guesses cannot all pass the throttle before the first failure is recorded. It is settled with RecordFailedLogin,
+// or ReleaseLoginAttempt when the credentials turn out to be right
+type LoginAttempt struct {
+	email     string
+	ip        string
+	throttles []*models.LoginThrottle
+}
+
+// CheckLoginAllowed returns how long the client has to wait before it may try to log in with the email
+// from the IP address. When a login attempt is allowed now the wait is 0 and the attempt is already counted
+func CheckLoginAllowed(email string, ip string) (*LoginAttempt, time.Duration, error) {
+
+	now := time.Now()
+	attempt := &LoginAttempt{email: normalizeEmail(email), ip: ip}
+
+	var retryAfter time.Duration
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// the throttle rows stay locked until the attempt is counted, concurrent attempts wait for each other
+		accountThrottle, err := lockLoginThrottle(tx, models.LoginThrottleAccount, attempt.email)
+
+		if err != nil {
+			return err
+		}
+
+		ipThrottle, err := lockLoginThrottle(tx, models.LoginThrottleIP, ip)
+
+		if err != nil {
+			return err
+		}
+
+		attempt.throttles = []*models.LoginThrottle{accountThrottle, ipThrottle}
+
+		for _, throttle := range attempt.throttles {
+			if throttle.BlockedUntil != nil {
+				if wait := throttle.BlockedUntil.Sub(now); wait > retryAfter {
+					retryAfter = wait
+				}
+			}
+		}
+
+		if retryAfter > 0 {
+			return nil
+		}
+
+		for _, throttle := range attempt.throttles {
+			if err := countLoginFailure(tx, throttle, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if retryAfter > 0 {
+		recordAudit(&models.AuditLog{
+			Event:     models.AuditLoginBlocked,
+			Email:     attempt.email,
+			IPAddress: ip,
+		})
+
+		return nil, retryAfter, nil
+	}
+
+	return attempt, 0, nil
+}
+
+// RecordFailedLogin records the failure of a counted login attempt, userID is nil when no user has the email
+func RecordFailedLogin(attempt *LoginAttempt, userID *uuid.UUID) {
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditLoginFailed,
+		UserID:    userID,
+		Email:     attempt.email,
+		IPAddress: attempt.ip,
+	})
+
+	for _, throttle := range attempt.throttles {
+		if throttle.Failures == loginLockoutThreshold(throttle.Kind) {
+			recordAudit(&models.AuditLog{
+				Event:     models.AuditLoginLocked,
+				UserID:    userID,
+				Email:     attempt.email,
+				IPAddress: attempt.ip,
+				Detail:    throttle.Kind + " locked until " + throttle.BlockedUntil.Format(time.RFC3339),
+			})
+		}
+	}
+}
+
+// ReleaseLoginAttempt takes back a counted login attempt that was not a failed guess, its block is shortened to
+// the backoff of the failures before it. Errors are only logged, the attempt then stays counted
+func ReleaseLoginAttempt(attempt *LoginAttempt) {
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, counted := range attempt.throttles {
+			var throttle models.LoginThrottle
+
+			// the throttle may have been cleared in the meantime
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", counted.ID).Limit(1).Find(&throttle).Error
+
+			if err != nil {
+				return err
+			}
+
+			if throttle.ID == uuid.Nil || throttle.Failures == 0 {
+				continue
+			}
+
+			throttle.Failures--
+			throttle.BlockedUntil = nil
+
+			if backoff := loginBackoff(throttle.Kind, throttle.Failures); backoff > 0 {
+				blockedUntil := throttle.LastFailureAt.Add(backoff)
+				throttle.BlockedUntil = &blockedUntil
+			}
+
+			if err := tx.Save(&throttle).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Failed to release login attempt for %s: %v", attempt.ip, err)
+	}
+}
+
+// RecordSuccessfulLogin forgets the failed logins of the account. Failures of the IP address are kept,
+// otherwise an attacker could reset them by logging in to an account of its own
+func RecordSuccessfulLogin(user *models.User, ip string) {
+
+	err := config.DB.
+		Where("kind = ? AND key = ?", models.LoginThrottleAccount, normalizeEmail(user.Email)).
+		Delete(&models.LoginThrottle{}).Error
+
+	if err != nil {
+		log.Printf("Failed to reset failed logins of user %s: %v", user.ID, err)
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditLoginSucceeded,
+		UserID:    &user.ID,
+		Email:     normalizeEmail(user.Email),
+		IPAddress: ip,
+	})
+}
+
+// GetLockouts returns the accounts and IP addresses that currently cannot log in
+func GetLockouts() ([]*dtos.LockoutDto, error) {
+
+	var throttles []*models.LoginThrottle
+
+	err := config.DB.Where("blocked_until > ?", time.Now()).Order("blocked_until DESC").Find(&throttles).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	returnLockouts := []*dtos.LockoutDto{}
+
+	for _, throttle := range throttles {
+		returnLockouts = append(returnLockouts, &dtos.LockoutDto{
+			ID:           throttle.ID.String(),
+			Kind:         throttle.Kind,
+			Key:          throttle.Key,
+			Failures:     throttle.Failures,
+			BlockedUntil: *throttle.BlockedUntil,
+		})
+	}
+
+	return returnLockouts, nil
+}
+
+// ClearLockout removes a lockout of an account or IP address and forgets its failed logins
+func ClearLockout(context *gin.Context, lockoutID string) *interfaces.ServiceError {
+
+	var throttle models.LoginThrottle
+
+	if err := config.DB.First(&throttle, "id = ?", lockoutID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	if err := config.DB.Delete(&throttle).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	entry := &models.AuditLog{
+		Event:  models.AuditLockoutCleared,
+		Detail: throttle.Kind + " unlocked",
+	}
+
+	if throttle.Kind == models.LoginThrottleAccount {
+		entry.Email = throttle.Key
+	} else {
+		entry.IPAddress = throttle.Key
+	}
+
+	if principal, err := GetPrincipal(context); err == nil {
+		entry.ActorID = &principal.UserID
+	}
+
+	recordAudit(entry)
+
+	return nil
+}
+
+// UnlockUser forgets the failed logins of the account of a user
+func UnlockUser(context *gin.Context, userID string) *interfaces.ServiceError {
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	err := config.DB.
+		Where("kind = ? AND key = ?", models.LoginThrottleAccount, normalizeEmail(user.Email)).
+		Delete(&models.LoginThrottle{}).Error
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	entry := &models.AuditLog{
+		Event:  models.AuditLockoutCleared,
+		UserID: &user.ID,
+		Email:  normalizeEmail(user.Email),
+		Detail: models.LoginThrottleAccount + " unlocked",
+	}
+
+	if principal, err := GetPrincipal(context); err == nil {
+		entry.ActorID = &principal.UserID
+	}
+
+	recordAudit(entry)
+
+	return nil
+}
+
+// lockLoginThrottle returns the throttle of an email or IP address, locked for the rest of the transaction
+func lockLoginThrottle(tx *gorm.DB, kind string, key string) (*models.LoginThrottle, error) {
+
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{
+		Kind:          kind,
+		Key:           key,
+		LastFailureAt: time.Now(),
+	}).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle models.LoginThrottle
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&throttle, "kind = ? AND key = ?", kind, key).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// countLoginFailure counts a failed login of a locked throttle and blocks it for the backoff that follows
+func countLoginFailure(tx *gorm.DB, throttle *models.LoginThrottle, now time.Time) error {
+
+	// failures are forgotten once nobody tried for a lockout duration
+	blocked := throttle.BlockedUntil != nil && now.Before(*throttle.BlockedUntil)
+	if !blocked && now.Sub(throttle.LastFailureAt) > loginLockoutDuration() {
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	if backoff := loginBackoff(throttle.Kind, throttle.Failures); backoff > 0 {
+		blockedUntil := now.Add(backoff)
+		throttle.BlockedUntil = &blockedUntil
+	}
+
+	return tx.Save(throttle).Error
+}
+
+// loginBackoff returns how long logins are blocked after a number of failures, it doubles with every
+// failure after the free attempts and turns into a lockout at the threshold
+func loginBackoff(kind string, failures int) time.Duration {
+
+	if failures >= loginLockoutThreshold(kind) {
+		return loginLockoutDuration()
+	}
+
+	excess := failures - loginFreeAttempts[kind]
+
+	if excess <= 0 {
+		return 0
+	}
+
+	// 2^9 seconds is already past the maximum, larger shifts could overflow
+	if excess > 10 {
+		return maxLoginBackoff
+	}
+
+	backoff := time.Second << (excess - 1)
+
+	if backoff > maxLoginBackoff {
+		return maxLoginBackoff
+	}
+
+	return backoff
+}
+
+func loginLockoutThreshold(kind string) int {
+	if kind == models.LoginThrottleIP {
+		return config.GetEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 100)
+	}
+
+	return config.GetEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10)
+}
+
+func loginLockoutDuration() time.Duration {
+	return config.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 30*time.Minute)
+}
+
+// normalizeEmail makes throttles and audit entries of one address match regardless of case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/mailer"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	magicLinkBindIP     = "ip"
+	magicLinkBindDevice = "device"
+)
+
+var errMagicLinkBinding = errors.New("the login link has to be opened on the device and network it was requested from")
+
+// RequestMagicLink mails a login link when the email belongs to a user. Callers get the same answer either way,
+// so the endpoint cannot be used to find registered emails. An address gets at most MAGIC_LINK_EMAIL_LIMIT links
+// per MAGIC_LINK_RATE_WINDOW, further requests are dropped silently, while an IP address that asked for more than
+// MAGIC_LINK_IP_LIMIT links gets the time until it may ask again. With device binding the returned secret has to
+// be kept in the browser and handed back with the link
+func RequestMagicLink(email string, ip string) (string, time.Duration, error) {
+
+	window := config.GetEnvDuration("MAGIC_LINK_RATE_WINDOW", 15*time.Minute)
+
+	retryAfter, err := magicLinkRetryAfter("ip_address = ?", ip, config.GetEnvInt("MAGIC_LINK_IP_LIMIT", 20), window)
+
+	if err != nil || retryAfter > 0 {
+		return "", retryAfter, err
+	}
+
+	deviceSecret := ""
+
+	// the secret is handed out for unknown emails too, otherwise the cookie would tell them apart
+	if magicLinkBindsTo(magicLinkBindDevice) {
+		if deviceSecret, err = randomToken(); err != nil {
+			return "", 0, err
+		}
+	}
+
+	user, err := GetUserByEmail(email)
+
+	if err != nil {
+		return deviceSecret, 0, nil
+	}
+
+	if serviceError := CheckAccountActive(user); serviceError != nil {
+		return deviceSecret, 0, nil
+	}
+
+	email = normalizeEmail(user.Email)
+
+	emailRetryAfter, err := magicLinkRetryAfter("email = ?", email, config.GetEnvInt("MAGIC_LINK_EMAIL_LIMIT", 3), window)
+
+	if err != nil || emailRetryAfter > 0 {
+		return deviceSecret, 0, err
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	ttl := config.GetEnvDuration("MAGIC_LINK_TTL", 15*time.Minute)
+
+	magicLink := models.MagicLink{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		Email:     email,
+		IPAddress: ip,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if deviceSecret != "" {
+		magicLink.DeviceHash = hashToken(deviceSecret)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// old links are kept for the rate limits until their window passed
+		if err := tx.Where("created_at < ?", time.Now().Add(-window)).Where("expires_at < ?", time.Now()).Delete(&models.MagicLink{}).Error; err != nil {
+			return err
+		}
+
+		// only the newest link of a user works
+		err := tx.Model(&models.MagicLink{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Omit("User").Create(&magicLink).Error
+	})
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	err = config.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Open the link below to log in, it works once and expires in " + ttl.String() + ".\n" +
+			"If you did not ask for a login link you can ignore this email.\n\n" +
+			os.Getenv("API_URL") + "/auth/magic-link/callback?token=" + url.QueryEscape(token) + "\n",
+	})
+
+	if err != nil {
+		log.Printf("Failed to send login link to user %s: %v", user.ID, err)
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditMagicLinkSent,
+		UserID:    &user.ID,
+		Email:     email,
+		IPAddress: ip,
+	})
+
+	return deviceSecret, 0, nil
+}
+
+// CompleteMagicLinkLogin uses up a login link and returns its user. A link opened on another network or in
+// another browser than the binding allows is refused without using it up, so the real user can still open it
+func CompleteMagicLinkLogin(token string, ip string, deviceSecret string) (*models.User, *interfaces.ServiceError) {
+
+	var magicLink models.MagicLink
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").
+			First(&magicLink, "token_hash = ?", hashToken(token)).Error
+
+		if err != nil || magicLink.UsedAt != nil || time.Now().After(magicLink.ExpiresAt) {
+			return errInvalidUserToken
+		}
+
+		if magicLinkBindsTo(magicLinkBindIP) && magicLink.IPAddress != ip {
+			return errMagicLinkBinding
+		}
+
+		if magicLink.DeviceHash != "" && (deviceSecret == "" || hashToken(deviceSecret) != magicLink.DeviceHash) {
+			return errMagicLinkBinding
+		}
+
+		if err := tx.Model(&magicLink).Omit("User").Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		// the user just proved to own the address
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", magicLink.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+
+	if err == errInvalidUserToken || err == errMagicLinkBinding {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	if serviceError := CheckAccountActive(&magicLink.User); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &magicLink.User, nil
+}
+
+// magicLinkBindsTo reports whether MAGIC_LINK_BINDING, a space separated list of "ip" and "device", contains the binding
+func magicLinkBindsTo(binding string) bool {
+	return containsString(strings.Fields(os.Getenv("MAGIC_LINK_BINDING")), binding)
+}
+
+// magicLinkRetryAfter returns how long to wait until another link may be sent, when limit links matching the
+// condition were sent within the window
+func magicLinkRetryAfter(condition string, value string, limit int, window time.Duration) (time.Duration, error) {
+
+	var links []*models.MagicLink
+
+	err := config.DB.Where(condition, value).Where("created_at > ?", time.Now().Add(-window)).
+		Order("created_at DESC").Limit(limit).Find(&links).Error
+
+	if err != nil || len(links) < limit {
+		return 0, err
+	}
+
+	// the oldest of the last links has to leave the window first
+	return time.Until(links[len(links)-1].CreatedAt.Add(window)), nil
+}
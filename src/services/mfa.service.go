@@ -0,0 +1,638 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	totpPeriod        = 30
+	recoveryCodeCount = 10
+)
+
+var (
+	errInvalidMfaCode   = errors.New("invalid code")
+	errTotpNotEnrolled  = errors.New("two factor authentication is not set up, start the enrollment first")
+	errTotpEnabled      = errors.New("two factor authentication is already enabled")
+	errTotpRequired     = errors.New("two factor authentication is required for admins")
+	errTotpNotEnabled   = errors.New("two factor authentication is not enabled")
+	totpValidateOptions = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+)
+
+// MfaRequired reports whether a login of the user needs a second factor, admins need one even before
+// they enrolled and have to enroll to finish their login
+func MfaRequired(user *models.User) bool {
+	return user.TotpEnabledAt != nil || userHasRole(user.ID, models.RoleAdmin)
+}
+
+// StartMfaChallenge returns the challenge token a client exchanges for real tokens with a TOTP or recovery code
+func StartMfaChallenge(user *models.User) (*dtos.MfaChallengeDto, error) {
+
+	token, err := createUserToken(config.DB, user.ID, models.UserTokenMfaChallenge, mfaChallengeTTL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.MfaChallengeDto{
+		ChallengeToken:     token,
+		EnrollmentRequired: user.TotpEnabledAt == nil,
+		ExpiresIn:          int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// GetMfaChallengeUser returns the user that is logging in with the challenge token
+func GetMfaChallengeUser(challengeToken string) (*models.User, *interfaces.ServiceError) {
+
+	userToken, err := findUserToken(config.DB, challengeToken, models.UserTokenMfaChallenge)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", userToken.UserID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errInvalidUserToken,
+			StatusCode: 401,
+		}
+	}
+
+	if serviceError := CheckAccountActive(&user); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &user, nil
+}
+
+// CompleteMfaChallenge exchanges a challenge token and a code for tokens and settles the counted login attempt.
+// A user that has to enroll confirms the enrollment with the code and gets its recovery codes along with the tokens
+func CompleteMfaChallenge(context *gin.Context, user *models.User, mfaVerifyDto *dtos.MfaVerifyDto, attempt *LoginAttempt) (*dtos.TokenDto, *interfaces.ServiceError) {
+
+	ip := context.ClientIP()
+
+	var tokens *dtos.TokenDto
+	var recoveryCodes []string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		enrolling := user.TotpEnabledAt == nil
+
+		if enrolling && user.TotpSecret == nil {
+			return errTotpNotEnrolled
+		}
+
+		valid, err := verifySecondFactor(tx, user, mfaVerifyDto.Code, !enrolling)
+
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return errInvalidMfaCode
+		}
+
+		if _, err := consumeUserToken(tx, mfaVerifyDto.ChallengeToken, models.UserTokenMfaChallenge); err != nil {
+			return err
+		}
+
+		if enrolling {
+			if recoveryCodes, err = enableTotp(tx, user); err != nil {
+				return err
+			}
+		}
+
+		sessionID, err := startSession(tx, user.ID, context)
+
+		if err != nil {
+			return err
+		}
+
+		tokens, _, err = issueTokens(tx, user, sessionID)
+
+		return err
+	})
+
+	settleMfaAttempt(attempt, user.ID, err)
+
+	switch err {
+	case nil:
+	case errInvalidMfaCode:
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	case errInvalidUserToken:
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	case errTotpNotEnrolled:
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	default:
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	RecordSuccessfulLogin(user, ip)
+
+	tokens.RecoveryCodes = recoveryCodes
+
+	return tokens, nil
+}
+
+// StartTotpEnrollmentWithChallenge starts the enrollment of a user that cannot finish its login without it
+func StartTotpEnrollmentWithChallenge(challengeToken string) (*dtos.TotpEnrollmentDto, *interfaces.ServiceError) {
+
+	user, serviceError := GetMfaChallengeUser(challengeToken)
+
+	if serviceError != nil {
+		return nil, serviceError
+	}
+
+	return startTotpEnrollment(user)
+}
+
+// StartTotpEnrollment creates a new TOTP secret for the authenticated user, it is only used once confirmed with a code
+func StartTotpEnrollment(context *gin.Context) (*dtos.TotpEnrollmentDto, *interfaces.ServiceError) {
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		return nil, serviceError
+	}
+
+	return startTotpEnrollment(user)
+}
+
+// CheckMfaCodeAllowed counts a code check of the authenticated user as a login attempt, so codes cannot be
+// guessed through the MFA settings either. When the account or IP address is locked the wait is returned instead
+func CheckMfaCodeAllowed(context *gin.Context) (*LoginAttempt, time.Duration, *interfaces.ServiceError) {
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		return nil, 0, serviceError
+	}
+
+	attempt, retryAfter, err := CheckLoginAllowed(user.Email, context.ClientIP())
+
+	if err != nil {
+		return nil, 0, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return attempt, retryAfter, nil
+}
+
+// ConfirmTotpEnrollment turns on two factor authentication once the user proved its authenticator works
+func ConfirmTotpEnrollment(context *gin.Context, totpCodeDto *dtos.TotpCodeDto, attempt *LoginAttempt) (*dtos.RecoveryCodesDto, *interfaces.ServiceError) {
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		ReleaseLoginAttempt(attempt)
+		return nil, serviceError
+	}
+
+	var recoveryCodes []string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		if user.TotpEnabledAt != nil {
+			return errTotpEnabled
+		}
+
+		if user.TotpSecret == nil {
+			return errTotpNotEnrolled
+		}
+
+		valid, err := verifySecondFactor(tx, user, totpCodeDto.Code, false)
+
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return errInvalidMfaCode
+		}
+
+		recoveryCodes, err = enableTotp(tx, user)
+
+		return err
+	})
+
+	settleMfaAttempt(attempt, user.ID, err)
+
+	if serviceError := mfaServiceError(err); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &dtos.RecoveryCodesDto{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableTotp turns off two factor authentication after checking a TOTP or recovery code, admins cannot turn it off
+func DisableTotp(context *gin.Context, totpCodeDto *dtos.TotpCodeDto, attempt *LoginAttempt) *interfaces.ServiceError {
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		ReleaseLoginAttempt(attempt)
+		return serviceError
+	}
+
+	if userHasRole(user.ID, models.RoleAdmin) {
+		ReleaseLoginAttempt(attempt)
+		return &interfaces.ServiceError{
+			Error:      errTotpRequired,
+			StatusCode: 403,
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		if user.TotpEnabledAt == nil {
+			return errTotpNotEnabled
+		}
+
+		valid, err := verifySecondFactor(tx, user, totpCodeDto.Code, true)
+
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return errInvalidMfaCode
+		}
+
+		err = tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     nil,
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+
+	settleMfaAttempt(attempt, user.ID, err)
+
+	if serviceError := mfaServiceError(err); serviceError != nil {
+		return serviceError
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:  models.AuditMfaDisabled,
+		UserID: &user.ID,
+		Email:  normalizeEmail(user.Email),
+	})
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user after checking a TOTP code
+func RegenerateRecoveryCodes(context *gin.Context, totpCodeDto *dtos.TotpCodeDto, attempt *LoginAttempt) (*dtos.RecoveryCodesDto, *interfaces.ServiceError) {
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		ReleaseLoginAttempt(attempt)
+		return nil, serviceError
+	}
+
+	var recoveryCodes []string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		if user.TotpEnabledAt == nil {
+			return errTotpNotEnabled
+		}
+
+		valid, err := verifySecondFactor(tx, user, totpCodeDto.Code, false)
+
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return errInvalidMfaCode
+		}
+
+		recoveryCodes, err = createRecoveryCodes(tx, user.ID)
+
+		return err
+	})
+
+	settleMfaAttempt(attempt, user.ID, err)
+
+	if serviceError := mfaServiceError(err); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &dtos.RecoveryCodesDto{RecoveryCodes: recoveryCodes}, nil
+}
+
+// settleMfaAttempt settles a login attempt counted for a code check, only a wrong code is a failed guess
+func settleMfaAttempt(attempt *LoginAttempt, userID uuid.UUID, err error) {
+	if err == errInvalidMfaCode {
+		RecordFailedLogin(attempt, &userID)
+		return
+	}
+
+	ReleaseLoginAttempt(attempt)
+}
+
+// principalUser loads the authenticated user
+func principalUser(context *gin.Context) (*models.User, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", principal.UserID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	return &user, nil
+}
+
+func startTotpEnrollment(user *models.User) (*dtos.TotpEnrollmentDto, *interfaces.ServiceError) {
+
+	if user.TotpEnabledAt != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errTotpEnabled,
+			StatusCode: 409,
+		}
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+
+	if issuer == "" {
+		issuer = "Movie API"
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	encryptedSecret, err := encryptSecret([]byte(key.Secret()))
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	// a pending secret is replaced, the user may have lost it before confirming
+	err = config.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    encryptedSecret,
+		"totp_last_step": 0,
+	}).Error
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	qrCode, err := key.Image(256, 256)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	var qrCodePng bytes.Buffer
+
+	if err := png.Encode(&qrCodePng, qrCode); err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return &dtos.TotpEnrollmentDto{
+		Secret:     key.Secret(),
+		OtpauthURI: key.URL(),
+		QrCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCodePng.Bytes()),
+	}, nil
+}
+
+// enableTotp turns on two factor authentication of a locked user and returns its first recovery codes
+func enableTotp(tx *gorm.DB, user *models.User) ([]string, error) {
+
+	now := time.Now()
+
+	if err := tx.Model(user).Update("totp_enabled_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	user.TotpEnabledAt = &now
+
+	recoveryCodes, err := createRecoveryCodes(tx, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:  models.AuditMfaEnabled,
+		UserID: &user.ID,
+		Email:  normalizeEmail(user.Email),
+	})
+
+	return recoveryCodes, nil
+}
+
+// verifySecondFactor checks a TOTP code of a locked user, or one of its recovery codes when allowed.
+// A TOTP code is accepted one period early or late, but never twice
+func verifySecondFactor(tx *gorm.DB, user *models.User, code string, allowRecoveryCode bool) (bool, error) {
+
+	code = strings.TrimSpace(code)
+
+	if len(code) == int(otp.DigitsSix) {
+		secret, err := decryptSecret(user.TotpSecret)
+
+		if err != nil {
+			return false, err
+		}
+
+		currentStep := time.Now().Unix() / totpPeriod
+
+		for step := currentStep - 1; step <= currentStep+1; step++ {
+			if step <= user.TotpLastStep {
+				continue
+			}
+
+			expected, err := totp.GenerateCodeCustom(string(secret), time.Unix(step*totpPeriod, 0), totpValidateOptions)
+
+			if err != nil {
+				return false, err
+			}
+
+			if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+				user.TotpLastStep = step
+				return true, tx.Model(user).Update("totp_last_step", step).Error
+			}
+		}
+
+		return false, nil
+	}
+
+	if !allowRecoveryCode {
+		return false, nil
+	}
+
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:  models.AuditRecoveryCodeUsed,
+		UserID: &user.ID,
+		Email:  normalizeEmail(user.Email),
+	})
+
+	return true, nil
+}
+
+// createRecoveryCodes replaces the recovery codes of the user, the codes are only returned here
+func createRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := []string{}
+	storedCodes := []*models.RecoveryCode{}
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 10)
+
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+
+		// 16 lower case base32 characters, grouped in fours to be easier to type
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+		code = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+
+		recoveryCodes = append(recoveryCodes, code)
+		storedCodes = append(storedCodes, &models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Omit("User").Create(&storedCodes).Error; err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func mfaServiceError(err error) *interfaces.ServiceError {
+	switch err {
+	case nil:
+		return nil
+	case errInvalidMfaCode:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 403,
+		}
+	case errTotpEnabled:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 409,
+		}
+	case errTotpNotEnrolled, errTotpNotEnabled:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	default:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/jaimy-monsuur/movie-api/src/config"
 	"github.com/jaimy-monsuur/movie-api/src/dtos"
 	"github.com/jaimy-monsuur/movie-api/src/interfaces"
 	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
 )
 
 func CreateMovie(movie dtos.CreateMovie) (*models.Movie, *interfaces.ServiceError) {
@@ -30,11 +37,21 @@ func CreateMovie(movie dtos.CreateMovie) (*models.Movie, *interfaces.ServiceErro
 		Length:   movie.Length,
 	}
 
-	result := config.DB.Create(&newMovie)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newMovie).Error; err != nil {
+			return err
+		}
+
+		if err := syncCreditsFromLegacyFields(tx, &newMovie); err != nil {
+			return err
+		}
+
+		return assignGenresAndKeywords(tx, &newMovie, movie.GenreIDs, movie.Keywords)
+	})
 
-	if result.Error != nil {
+	if err != nil {
 		movieCreateError := &interfaces.ServiceError{
-			Error:      result.Error,
+			Error:      err,
 			StatusCode: 400,
 		}
 		return nil, movieCreateError
@@ -43,22 +60,360 @@ func CreateMovie(movie dtos.CreateMovie) (*models.Movie, *interfaces.ServiceErro
 	return &newMovie, nil
 }
 
-func GetAllMovies() ([]*models.Movie, error) {
+const (
+	defaultMoviePageLimit = 20
+	defaultMovieSort      = "created_at"
+	defaultMovieOrder     = "desc"
+)
+
+// movieCursor points at the last movie of a page, Value holds the sort column value of that movie.
+// Cursors of a previous page point at the first movie of the current page and have Before set
+type movieCursor struct {
+	Sort   string          `json:"s"`
+	Value  json.RawMessage `json:"v"`
+	ID     uuid.UUID       `json:"id"`
+	Before bool            `json:"b,omitempty"`
+}
+
+func GetAllMovies(query dtos.MovieQueryDto) ([]*models.Movie, *dtos.PaginationDto, *interfaces.ServiceError) {
+	if query.Limit == 0 {
+		query.Limit = defaultMoviePageLimit
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.Sort == "" {
+		query.Sort = defaultMovieSort
+	}
+	if query.Order == "" {
+		query.Order = defaultMovieOrder
+	}
+
+	var total int64
+
+	if err := filterMovies(config.DB.Model(&models.Movie{}), query).Count(&total).Error; err != nil {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	pagination := &dtos.PaginationDto{
+		Total:      total,
+		Limit:      query.Limit,
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+
+	db := filterMovies(config.DB, query).
+		Select("id", "title", "year", "director", "actors", "plot", "language", "length", "avg_rating", "nr_of_ratings", "created_at", "updated_at").
+		Preload("Genres").
+		Preload("Keywords").
+		Limit(query.Limit + 1)
+
+	// a previous page is read backwards from the first movie of the current page and reversed afterwards
+	order := query.Order
+	before := false
+
+	if query.UseCursor && query.Cursor != "" {
+		cursorValue, cursorID, cursorBefore, err := decodeMovieCursor(query)
+
+		if err != nil {
+			return nil, nil, &interfaces.ServiceError{
+				Error:      err,
+				StatusCode: 400,
+			}
+		}
+
+		before = cursorBefore
+
+		if before {
+			order = reverseOrder(order)
+		}
+
+		operator := ">"
+		if order == "desc" {
+			operator = "<"
+		}
+
+		db = db.Where("("+query.Sort+", id) "+operator+" (?, ?)", cursorValue, cursorID)
+	} else if !query.UseCursor {
+		pagination.Page = query.Page
+		db = db.Offset((query.Page - 1) * query.Limit)
+	}
+
 	var allMovies []*models.Movie
 
-	err := config.DB.Select("id", "title", "year", "director", "actors", "plot", "language", "length", "created_at", "updated_at").Find(&allMovies).Error
+	if err := db.Order(query.Sort + " " + order).Order("id " + order).Find(&allMovies).Error; err != nil {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	hasMore := len(allMovies) > query.Limit
+
+	if hasMore {
+		allMovies = allMovies[:query.Limit]
+	}
+
+	if before {
+		for i, j := 0, len(allMovies)-1; i < j; i, j = i+1, j-1 {
+			allMovies[i], allMovies[j] = allMovies[j], allMovies[i]
+		}
+	}
+
+	if !query.UseCursor || len(allMovies) == 0 {
+		return allMovies, pagination, nil
+	}
+
+	// reading forwards there are more movies after the page when the extra row was found and movies before it
+	// when the page started from a cursor, reading backwards it is the other way around
+	hasNext, hasPrev := hasMore, query.Cursor != ""
+
+	if before {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	var err error
+
+	if hasNext {
+		pagination.NextCursor, err = encodeMovieCursor(query, allMovies[len(allMovies)-1], false)
+	}
+
+	if hasPrev && err == nil {
+		pagination.PrevCursor, err = encodeMovieCursor(query, allMovies[0], true)
+	}
+
+	if err != nil {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return allMovies, pagination, nil
+}
+
+func reverseOrder(order string) string {
+	if order == "desc" {
+		return "asc"
+	}
+
+	return "desc"
+}
+
+func filterMovies(db *gorm.DB, query dtos.MovieQueryDto) *gorm.DB {
+	if query.YearFrom != 0 {
+		db = db.Where("movies.year >= ?", query.YearFrom)
+	}
+	if query.YearTo != 0 {
+		db = db.Where("movies.year <= ?", query.YearTo)
+	}
+	if query.Language != "" {
+		db = db.Where("LOWER(movies.language) = LOWER(?)", query.Language)
+	}
+	if query.Director != "" {
+		db = db.Where("movies.director ILIKE ?", "%"+query.Director+"%")
+	}
+	if query.MinRating != 0 {
+		db = db.Where("movies.avg_rating >= ?", query.MinRating)
+	}
+	if query.MinLength != 0 {
+		db = db.Where("movies.length >= ?", query.MinLength)
+	}
+	if query.MaxLength != 0 {
+		db = db.Where("movies.length <= ?", query.MaxLength)
+	}
+	if query.Decade != 0 {
+		db = db.Where("movies.year >= ? AND movies.year < ?", query.Decade, query.Decade+10)
+	}
+	for _, genre := range query.Genres {
+		db = db.Where(`EXISTS (SELECT 1 FROM movie_genres JOIN genres ON genres.id = movie_genres.genre_id
+			WHERE movie_genres.movie_id = movies.id AND LOWER(genres.name) = LOWER(?))`, genre)
+	}
+	for _, keyword := range query.Keywords {
+		db = db.Where(`EXISTS (SELECT 1 FROM movie_keywords JOIN keywords ON keywords.id = movie_keywords.keyword_id
+			WHERE movie_keywords.movie_id = movies.id AND keywords.name = ?)`, normalizeKeyword(keyword))
+	}
+
+	return db
+}
+
+// GetMovieFacets counts the movies matching the filters of the query per genre, decade and language
+func GetMovieFacets(query dtos.MovieQueryDto) (*dtos.MovieFacetsDto, error) {
+	facets := &dtos.MovieFacetsDto{
+		Genres:    []*dtos.FacetDto{},
+		Decades:   []*dtos.FacetDto{},
+		Languages: []*dtos.FacetDto{},
+	}
+
+	err := filterMovies(config.DB.Model(&models.Movie{}), query).
+		Joins("JOIN movie_genres ON movie_genres.movie_id = movies.id").
+		Joins("JOIN genres ON genres.id = movie_genres.genre_id").
+		Select("genres.name AS value, COUNT(*) AS count").
+		Group("genres.name").
+		Order("count DESC, value").
+		Scan(&facets.Genres).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = filterMovies(config.DB.Model(&models.Movie{}), query).
+		Where("movies.year > 0").
+		Select("((movies.year / 10) * 10)::text AS value, COUNT(*) AS count").
+		Group("value").
+		Order("value DESC").
+		Scan(&facets.Decades).Error
 
 	if err != nil {
 		return nil, err
 	}
 
-	return allMovies, nil
+	err = filterMovies(config.DB.Model(&models.Movie{}), query).
+		Where("movies.language <> ''").
+		Select("movies.language AS value, COUNT(*) AS count").
+		Group("movies.language").
+		Order("count DESC, value").
+		Scan(&facets.Languages).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// assignGenresAndKeywords replaces the genres and keywords of a movie, a nil list leaves them unchanged
+func assignGenresAndKeywords(tx *gorm.DB, movie *models.Movie, genreIDs []string, keywordNames []string) error {
+	if genreIDs != nil {
+		genres := []models.Genre{}
+		uniqueGenreIDs := map[string]bool{}
+
+		for _, genreID := range genreIDs {
+			uniqueGenreIDs[strings.ToLower(genreID)] = true
+		}
+
+		if len(genreIDs) > 0 {
+			if err := tx.Find(&genres, "id IN ?", genreIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(genres) != len(uniqueGenreIDs) {
+			return errors.New("one or more genres do not exist")
+		}
+
+		if err := replaceAssociation(tx, movie, "Genres", genres, len(genres)); err != nil {
+			return err
+		}
+	}
+
+	if keywordNames != nil {
+		keywords, err := findOrCreateKeywords(tx, keywordNames)
+
+		if err != nil {
+			return err
+		}
+
+		if err := replaceAssociation(tx, movie, "Keywords", keywords, len(keywords)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replaceAssociation(tx *gorm.DB, movie *models.Movie, association string, values interface{}, count int) error {
+	if count == 0 {
+		return tx.Model(movie).Association(association).Clear()
+	}
+
+	return tx.Model(movie).Association(association).Replace(values)
+}
+
+func encodeMovieCursor(query dtos.MovieQueryDto, movie *models.Movie, before bool) (string, error) {
+	var value interface{}
+
+	switch query.Sort {
+	case "title":
+		value = movie.Title
+	case "year":
+		value = movie.Year
+	case "avg_rating":
+		value = movie.AVGRating
+	default:
+		value = movie.CreatedAt
+	}
+
+	rawValue, err := json.Marshal(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	rawCursor, err := json.Marshal(movieCursor{Sort: query.Sort + " " + query.Order, Value: rawValue, ID: movie.ID, Before: before})
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(rawCursor), nil
+}
+
+// decodeMovieCursor returns the sort column value and id the page starts after, or ends before when the
+// returned bool is set, the value is decoded into the Go type of the sort column
+func decodeMovieCursor(query dtos.MovieQueryDto) (interface{}, uuid.UUID, bool, error) {
+	invalidCursorError := errors.New("invalid cursor")
+
+	rawCursor, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+
+	if err != nil {
+		return nil, uuid.Nil, false, invalidCursorError
+	}
+
+	var cursor movieCursor
+
+	if err := json.Unmarshal(rawCursor, &cursor); err != nil {
+		return nil, uuid.Nil, false, invalidCursorError
+	}
+
+	if cursor.Sort != query.Sort+" "+query.Order {
+		return nil, uuid.Nil, false, errors.New("cursor does not match the requested sort order")
+	}
+
+	var value interface{}
+
+	switch query.Sort {
+	case "title":
+		var title string
+		err = json.Unmarshal(cursor.Value, &title)
+		value = title
+	case "year":
+		var year int
+		err = json.Unmarshal(cursor.Value, &year)
+		value = year
+	case "avg_rating":
+		var rating float64
+		err = json.Unmarshal(cursor.Value, &rating)
+		value = rating
+	default:
+		var createdAt time.Time
+		err = json.Unmarshal(cursor.Value, &createdAt)
+		value = createdAt
+	}
+
+	if err != nil {
+		return nil, uuid.Nil, false, invalidCursorError
+	}
+
+	return value, cursor.ID, cursor.Before, nil
 }
 
 func GetMovieById(ID string) (*models.Movie, error) {
 	var movie models.Movie
 
-	if err := config.DB.First(&movie, "id = ?", ID).Error; err != nil {
+	if err := config.DB.Preload("Genres").Preload("Keywords").First(&movie, "id = ?", ID).Error; err != nil {
 
 		return nil, err
 	}
@@ -69,12 +424,14 @@ func GetMovieById(ID string) (*models.Movie, error) {
 func UpdateMovie(id string, movie dtos.UpdateMovie) (*models.Movie, error) {
 	var movieToUpdate models.Movie
 
-	err := config.DB.First(&movieToUpdate, "id = ?", id).Error
+	err := config.DB.Preload("Genres").Preload("Keywords").First(&movieToUpdate, "id = ?", id).Error
 
 	if err != nil {
 		return nil, err
 	}
 
+	creditsChanged := movieToUpdate.Director != movie.Director || movieToUpdate.Actors != movie.Actors
+
 	movieToUpdate.Title = movie.Title
 	movieToUpdate.Year = movie.Year
 	movieToUpdate.Director = movie.Director
@@ -83,7 +440,20 @@ func UpdateMovie(id string, movie dtos.UpdateMovie) (*models.Movie, error) {
 	movieToUpdate.Language = movie.Language
 	movieToUpdate.Length = movie.Length
 
-	err = config.DB.Save(&movieToUpdate).Error
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// the rating aggregates are owned by the review writes, saving the values loaded above could undo a concurrent review
+		if err := tx.Omit("AVGRating", "NrOfRatings").Save(&movieToUpdate).Error; err != nil {
+			return err
+		}
+
+		if creditsChanged {
+			if err := syncCreditsFromLegacyFields(tx, &movieToUpdate); err != nil {
+				return err
+			}
+		}
+
+		return assignGenresAndKeywords(tx, &movieToUpdate, movie.GenreIDs, movie.Keywords)
+	})
 
 	if err != nil {
 		return nil, err
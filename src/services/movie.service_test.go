@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+)
+
+func TestMovieCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2022, 10, 3, 14, 5, 6, 123456000, time.UTC)
+
+	movie := &models.Movie{
+		Base:      models.Base{ID: uuid.New(), CreatedAt: createdAt},
+		Title:     "Heat",
+		Year:      1995,
+		AVGRating: 4.25,
+	}
+
+	tests := []struct {
+		sort   string
+		order  string
+		before bool
+		want   interface{}
+	}{
+		{sort: "title", order: "asc", want: "Heat"},
+		{sort: "year", order: "desc", want: 1995},
+		{sort: "avg_rating", order: "desc", want: 4.25},
+		{sort: "created_at", order: "asc", want: createdAt},
+		{sort: "title", order: "desc", before: true, want: "Heat"},
+	}
+
+	for _, test := range tests {
+		query := dtos.MovieQueryDto{Sort: test.sort, Order: test.order}
+
+		cursor, err := encodeMovieCursor(query, movie, test.before)
+		if err != nil {
+			t.Fatalf("%s %s: encode: %v", test.sort, test.order, err)
+		}
+
+		query.Cursor = cursor
+
+		value, id, before, err := decodeMovieCursor(query)
+		if err != nil {
+			t.Fatalf("%s %s: decode: %v", test.sort, test.order, err)
+		}
+
+		if createdAtValue, ok := value.(time.Time); ok {
+			if !createdAtValue.Equal(createdAt) {
+				t.Errorf("%s %s: value = %v, want %v", test.sort, test.order, value, test.want)
+			}
+		} else if value != test.want {
+			t.Errorf("%s %s: value = %v (%T), want %v (%T)", test.sort, test.order, value, value, test.want, test.want)
+		}
+
+		if id != movie.ID {
+			t.Errorf("%s %s: id = %s, want %s", test.sort, test.order, id, movie.ID)
+		}
+
+		if before != test.before {
+			t.Errorf("%s %s: before = %v, want %v", test.sort, test.order, before, test.before)
+		}
+	}
+}
+
+func TestDecodeMovieCursorRejectsInvalidCursors(t *testing.T) {
+	movie := &models.Movie{Base: models.Base{ID: uuid.New()}, Title: "Heat"}
+
+	titleCursor, err := encodeMovieCursor(dtos.MovieQueryDto{Sort: "title", Order: "asc"}, movie, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query dtos.MovieQueryDto
+	}{
+		{name: "not base64", query: dtos.MovieQueryDto{Sort: "title", Order: "asc", PaginationQueryDto: dtos.PaginationQueryDto{Cursor: "%%%"}}},
+		{name: "not json", query: dtos.MovieQueryDto{Sort: "title", Order: "asc", PaginationQueryDto: dtos.PaginationQueryDto{Cursor: "bm90IGpzb24"}}},
+		{name: "other sort", query: dtos.MovieQueryDto{Sort: "year", Order: "asc", PaginationQueryDto: dtos.PaginationQueryDto{Cursor: titleCursor}}},
+		{name: "other order", query: dtos.MovieQueryDto{Sort: "title", Order: "desc", PaginationQueryDto: dtos.PaginationQueryDto{Cursor: titleCursor}}},
+	}
+
+	for _, test := range tests {
+		if _, _, _, err := decodeMovieCursor(test.query); err == nil {
+			t.Errorf("%s: decoded an invalid cursor", test.name)
+		}
+	}
+}
@@ -0,0 +1,811 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// app tokens start with a prefix, so they are told apart from JWTs and personal access tokens
+	oauthAccessTokenPrefix  = "oat_"
+	oauthRefreshTokenPrefix = "ort_"
+
+	oauthAccessTokenTTL       = time.Hour
+	oauthAuthorizationCodeTTL = time.Minute
+)
+
+// OAuth2 error codes, RFC 6749 section 5.2
+const (
+	oauthInvalidRequest          = "invalid_request"
+	oauthInvalidClient           = "invalid_client"
+	oauthInvalidGrant            = "invalid_grant"
+	oauthUnauthorizedClient      = "unauthorized_client"
+	oauthUnsupportedGrantType    = "unsupported_grant_type"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+	oauthInvalidScope            = "invalid_scope"
+	oauthAccessDenied            = "access_denied"
+)
+
+// PKCE code challenges and verifiers, RFC 7636 section 4.1
+var pkcePattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+var errInvalidOAuthToken = errors.New("invalid app token")
+
+// OAuthError is an error of the OAuth2 protocol, answered with its error code instead of the usual error response
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (err *OAuthError) Error() string {
+	return err.Description
+}
+
+// authorizationRequest is an authorization request that names a known app and one of its redirect URIs,
+// errors about the rest of the request are sent back to the app
+type authorizationRequest struct {
+	client           *models.OAuthClient
+	redirectURI      string
+	redirectURIGiven bool
+	state            string
+	scopes           []string
+	codeChallenge    string
+}
+
+// StartAuthorization checks an authorization request and returns where to send the browser: to the consent
+// screen of the front end, or back to the app with an error
+func StartAuthorization(requestDto *dtos.AuthorizationRequestDto) (string, *interfaces.ServiceError) {
+
+	request, err := parseAuthorizationRequest(requestDto)
+
+	if request == nil {
+		return "", authorizationServiceError(err)
+	}
+
+	if err != nil {
+		return request.errorRedirect(err), nil
+	}
+
+	query := url.Values{}
+	query.Set("response_type", requestDto.ResponseType)
+	query.Set("client_id", request.client.ClientID)
+
+	// the consent is decided on the request as the app sent it, a left out redirect URI stays left out
+	if request.redirectURIGiven {
+		query.Set("redirect_uri", request.redirectURI)
+	}
+
+	query.Set("scope", strings.Join(request.scopes, " "))
+	query.Set("state", request.state)
+	query.Set("code_challenge", request.codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	return os.Getenv("APP_URL") + "/oauth/consent?" + query.Encode(), nil
+}
+
+// GetConsent returns what the consent screen shows the authenticated user: the app and the scopes it gets,
+// which are the requested scopes the roles of the user grant
+func GetConsent(context *gin.Context, requestDto *dtos.AuthorizationRequestDto) (*dtos.ConsentDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	request, err := parseAuthorizationRequest(requestDto)
+
+	if err != nil {
+		return nil, authorizationServiceError(err)
+	}
+
+	scopes, err := grantableScopes(principal.UserID, request.scopes)
+
+	if err != nil {
+		return nil, authorizationServiceError(err)
+	}
+
+	consent := &dtos.ConsentDto{
+		ClientName:  request.client.Name,
+		ClientID:    request.client.ClientID,
+		RedirectURI: request.redirectURI,
+		Scopes:      []*dtos.PermissionDto{},
+	}
+
+	for _, definition := range permissionDefinitions {
+		if containsString(scopes, definition.Name) {
+			consent.Scopes = append(consent.Scopes, &dtos.PermissionDto{Name: definition.Name, Description: definition.Description})
+		}
+	}
+
+	return consent, nil
+}
+
+// DecideConsent records the answer of the authenticated user on the consent screen and returns where to send
+// the browser: back to the app with an authorization code, or with an error when the user declined
+func DecideConsent(context *gin.Context, decisionDto *dtos.ConsentDecisionDto) (*dtos.AuthorizationResponseDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	// only the user can hand out access, not a script or another app acting for it
+	if principal.Delegated() {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("personal access tokens and app tokens cannot authorize apps"),
+			StatusCode: 403,
+		}
+	}
+
+	request, err := parseAuthorizationRequest(&decisionDto.AuthorizationRequestDto)
+
+	if request == nil {
+		return nil, authorizationServiceError(err)
+	}
+
+	if err != nil {
+		return &dtos.AuthorizationResponseDto{RedirectURI: request.errorRedirect(err)}, nil
+	}
+
+	if !decisionDto.Approved {
+		return &dtos.AuthorizationResponseDto{
+			RedirectURI: request.errorRedirect(&OAuthError{Code: oauthAccessDenied, Description: "the user declined"}),
+		}, nil
+	}
+
+	scopes, err := grantableScopes(principal.UserID, request.scopes)
+
+	if err != nil {
+		if _, ok := err.(*OAuthError); ok {
+			return &dtos.AuthorizationResponseDto{RedirectURI: request.errorRedirect(err)}, nil
+		}
+
+		return nil, authorizationServiceError(err)
+	}
+
+	code, err := randomToken()
+
+	if err != nil {
+		return nil, authorizationServiceError(err)
+	}
+
+	config.DB.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAuthorizationCode{})
+
+	err = config.DB.Omit("Client", "User").Create(&models.OAuthAuthorizationCode{
+		ClientID:         request.client.ID,
+		UserID:           principal.UserID,
+		CodeHash:         hashToken(code),
+		RedirectURI:      request.redirectURI,
+		RedirectURIGiven: request.redirectURIGiven,
+		Scopes:           strings.Join(scopes, " "),
+		CodeChallenge:    request.codeChallenge,
+		GrantID:          uuid.New(),
+		ExpiresAt:        time.Now().Add(oauthAuthorizationCodeTTL),
+	}).Error
+
+	if err != nil {
+		return nil, authorizationServiceError(err)
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditOAuthConsentGranted,
+		UserID:    &principal.UserID,
+		Email:     normalizeEmail(principal.Email),
+		IPAddress: context.ClientIP(),
+		Detail:    request.client.Name + ": " + strings.Join(scopes, " "),
+	})
+
+	query := url.Values{}
+	query.Set("code", code)
+
+	if request.state != "" {
+		query.Set("state", request.state)
+	}
+
+	return &dtos.AuthorizationResponseDto{RedirectURI: appendQuery(request.redirectURI, query)}, nil
+}
+
+// ExchangeOAuthToken answers a token request of an app with the authorization code, refresh token or
+// client credentials grant. The client credentials may come from HTTP basic authentication or the form
+func ExchangeOAuthToken(requestDto *dtos.TokenRequestDto, clientID string, clientSecret string) (*dtos.OAuthTokenDto, *interfaces.ServiceError) {
+
+	client, err := authenticateOAuthClient(clientID, clientSecret)
+
+	if err != nil {
+		return nil, oauthServiceError(err)
+	}
+
+	var tokens *dtos.OAuthTokenDto
+
+	switch requestDto.GrantType {
+	case "authorization_code":
+		tokens, err = exchangeAuthorizationCode(client, requestDto)
+	case "refresh_token":
+		tokens, err = exchangeRefreshToken(client, requestDto)
+	case "client_credentials":
+		tokens, err = exchangeClientCredentials(client, requestDto)
+	default:
+		err = &OAuthError{Code: oauthUnsupportedGrantType, Description: "unsupported grant type: " + requestDto.GrantType}
+	}
+
+	if err != nil {
+		return nil, oauthServiceError(err)
+	}
+
+	return tokens, nil
+}
+
+// IntrospectOAuthToken describes a token to the confidential app it was issued to, RFC 7662. Tokens of
+// other apps are reported inactive like unknown ones
+func IntrospectOAuthToken(operationDto *dtos.TokenOperationDto, clientID string, clientSecret string) (*dtos.IntrospectionDto, *interfaces.ServiceError) {
+
+	client, err := authenticateOAuthClient(clientID, clientSecret)
+
+	if err != nil {
+		return nil, oauthServiceError(err)
+	}
+
+	if !client.Confidential {
+		return nil, oauthServiceError(&OAuthError{Code: oauthUnauthorizedClient, Description: "only confidential apps can introspect tokens"})
+	}
+
+	var token models.OAuthToken
+
+	err = config.DB.Preload("User").
+		First(&token, "token_hash = ? AND client_id = ?", hashToken(operationDto.Token), client.ID).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &dtos.IntrospectionDto{Active: false}, nil
+	}
+
+	if err != nil {
+		return nil, oauthServiceError(err)
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) || token.User.DisabledAt != nil {
+		return &dtos.IntrospectionDto{Active: false}, nil
+	}
+
+	tokenType := "Bearer"
+
+	if token.Kind == models.OAuthTokenRefresh {
+		tokenType = "refresh_token"
+	}
+
+	return &dtos.IntrospectionDto{
+		Active:    true,
+		Scope:     token.Scopes,
+		ClientID:  client.ClientID,
+		Username:  token.User.Email,
+		TokenType: tokenType,
+		Exp:       token.ExpiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+		Sub:       token.UserID.String(),
+		Iss:       "movie-api",
+	}, nil
+}
+
+// RevokeOAuthToken revokes a token of the app, RFC 7009. Revoking a refresh token revokes every token of the
+// grant. Unknown tokens are not an error, the app only learns that the token does not work anymore
+func RevokeOAuthToken(operationDto *dtos.TokenOperationDto, clientID string, clientSecret string) *interfaces.ServiceError {
+
+	client, err := authenticateOAuthClient(clientID, clientSecret)
+
+	if err != nil {
+		return oauthServiceError(err)
+	}
+
+	var token models.OAuthToken
+
+	err = config.DB.First(&token, "token_hash = ? AND client_id = ?", hashToken(operationDto.Token), client.ID).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return oauthServiceError(err)
+	}
+
+	tokens := config.DB.Where("id = ?", token.ID)
+
+	if token.Kind == models.OAuthTokenRefresh {
+		tokens = config.DB.Where("grant_id = ?", token.GrantID)
+	}
+
+	if err := revokeOAuthTokens(tokens); err != nil {
+		return oauthServiceError(err)
+	}
+
+	return nil
+}
+
+// authenticateOAuthToken returns the principal of an app access token, its scopes are limited to the
+// permissions the roles of the user still grant
+func authenticateOAuthToken(token string) (*interfaces.Principal, error) {
+
+	var storedToken models.OAuthToken
+
+	err := config.DB.Preload("User.Roles.Permissions").Preload("Client").
+		First(&storedToken, "token_hash = ? AND kind = ?", hashToken(token), models.OAuthTokenAccess).Error
+
+	if err != nil {
+		return nil, errInvalidOAuthToken
+	}
+
+	if storedToken.RevokedAt != nil || time.Now().After(storedToken.ExpiresAt) || storedToken.User.DisabledAt != nil {
+		return nil, errInvalidOAuthToken
+	}
+
+	user := storedToken.User
+	permissions := rolePermissionNames(user.Roles)
+	scopes := []string{}
+
+	for _, scope := range strings.Fields(storedToken.Scopes) {
+		if containsString(permissions, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &interfaces.Principal{
+		UserID:         user.ID,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerifiedAt != nil,
+		Roles:          roleNames(user.Roles),
+		Scopes:         scopes,
+		TokenID:        storedToken.ID.String(),
+		TokenExpiresAt: storedToken.ExpiresAt,
+		OAuthClientID:  storedToken.Client.ClientID,
+	}, nil
+}
+
+// parseAuthorizationRequest checks an authorization request. Without a known app and redirect URI nil is returned,
+// as the error cannot be sent back to the app. Other errors are returned together with the request
+func parseAuthorizationRequest(requestDto *dtos.AuthorizationRequestDto) (*authorizationRequest, error) {
+
+	var client models.OAuthClient
+
+	if err := config.DB.First(&client, "client_id = ?", requestDto.ClientID).Error; err != nil {
+		return nil, &OAuthError{Code: oauthInvalidClient, Description: "unknown app"}
+	}
+
+	redirectURIs := strings.Fields(client.RedirectURIs)
+	redirectURI := requestDto.RedirectURI
+
+	// the redirect URI can only be left out when the app registered exactly one
+	if redirectURI == "" && len(redirectURIs) == 1 {
+		redirectURI = redirectURIs[0]
+	}
+
+	if !containsString(redirectURIs, redirectURI) {
+		return nil, &OAuthError{Code: oauthInvalidRequest, Description: "redirect URI is not registered for the app"}
+	}
+
+	request := &authorizationRequest{
+		client:           &client,
+		redirectURI:      redirectURI,
+		redirectURIGiven: requestDto.RedirectURI != "",
+		state:            requestDto.State,
+	}
+
+	if requestDto.ResponseType != "code" {
+		return request, &OAuthError{Code: oauthUnsupportedResponseType, Description: "only the code response type is supported"}
+	}
+
+	// every app has to use PKCE, public apps cannot keep a secret and confidential apps are protected against code injection
+	if requestDto.CodeChallengeMethod != "S256" || !pkcePattern.MatchString(requestDto.CodeChallenge) {
+		return request, &OAuthError{Code: oauthInvalidRequest, Description: "a PKCE code challenge with the S256 method is required"}
+	}
+
+	scopes, err := requestedScopes(&client, requestDto.Scope)
+
+	if err != nil {
+		return request, err
+	}
+
+	request.scopes = scopes
+	request.codeChallenge = requestDto.CodeChallenge
+
+	return request, nil
+}
+
+// errorRedirect returns the redirect URI of the request carrying an error, RFC 6749 section 4.1.2.1
+func (request *authorizationRequest) errorRedirect(err error) string {
+
+	query := url.Values{}
+	query.Set("error", oauthInvalidRequest)
+
+	if oauthError, ok := err.(*OAuthError); ok {
+		query.Set("error", oauthError.Code)
+		query.Set("error_description", oauthError.Description)
+	}
+
+	if request.state != "" {
+		query.Set("state", request.state)
+	}
+
+	return appendQuery(request.redirectURI, query)
+}
+
+func exchangeAuthorizationCode(client *models.OAuthClient, requestDto *dtos.TokenRequestDto) (*dtos.OAuthTokenDto, error) {
+
+	var tokens *dtos.OAuthTokenDto
+	var reusedGrantID *uuid.UUID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var code models.OAuthAuthorizationCode
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").
+			First(&code, "code_hash = ? AND client_id = ?", hashToken(requestDto.Code), client.ID).Error
+
+		if err != nil {
+			return &OAuthError{Code: oauthInvalidGrant, Description: "invalid authorization code"}
+		}
+
+		// a code used twice was intercepted, the tokens it was exchanged for are revoked outside this transaction
+		if code.UsedAt != nil {
+			reusedGrantID = &code.GrantID
+			return &OAuthError{Code: oauthInvalidGrant, Description: "authorization code was already used"}
+		}
+
+		if err := checkAuthorizationCode(&code, requestDto, time.Now()); err != nil {
+			return err
+		}
+
+		if code.User.DisabledAt != nil {
+			return &OAuthError{Code: oauthInvalidGrant, Description: errUserDisabled.Error()}
+		}
+
+		if err := tx.Model(&code).Omit("Client", "User").Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		scopes := strings.Fields(code.Scopes)
+
+		tokens, err = issueOAuthTokens(tx, client, code.UserID, code.GrantID, scopes, scopes)
+
+		return err
+	})
+
+	if reusedGrantID != nil {
+		revokeOAuthTokens(config.DB.Where("grant_id = ?", *reusedGrantID))
+	}
+
+	return tokens, err
+}
+
+// checkAuthorizationCode checks that a token request may redeem an unused authorization code
+func checkAuthorizationCode(code *models.OAuthAuthorizationCode, requestDto *dtos.TokenRequestDto, now time.Time) error {
+
+	if now.After(code.ExpiresAt) {
+		return &OAuthError{Code: oauthInvalidGrant, Description: "authorization code expired"}
+	}
+
+	// RFC 6749 section 4.1.3, a redirect URI named in the authorization request must be named again
+	if code.RedirectURIGiven && requestDto.RedirectURI != code.RedirectURI {
+		return &OAuthError{Code: oauthInvalidGrant, Description: "redirect URI does not match the authorization request"}
+	}
+
+	if !pkcePattern.MatchString(requestDto.CodeVerifier) {
+		return &OAuthError{Code: oauthInvalidRequest, Description: "a PKCE code verifier is required"}
+	}
+
+	if !verifyCodeChallenge(requestDto.CodeVerifier, code.CodeChallenge) {
+		return &OAuthError{Code: oauthInvalidGrant, Description: "code verifier does not match the code challenge"}
+	}
+
+	return nil
+}
+
+// verifyCodeChallenge reports whether the S256 code challenge was made from the code verifier, RFC 7636 section 4.6
+func verifyCodeChallenge(codeVerifier string, codeChallenge string) bool {
+
+	hash := sha256.Sum256([]byte(codeVerifier))
+
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(hash[:])), []byte(codeChallenge)) == 1
+}
+
+// exchangeRefreshToken replaces a refresh token with new tokens of the same grant. A refresh token can only be used
+// once, using it again means it was stolen, so the whole grant is revoked
+func exchangeRefreshToken(client *models.OAuthClient, requestDto *dtos.TokenRequestDto) (*dtos.OAuthTokenDto, error) {
+
+	var tokens *dtos.OAuthTokenDto
+	var reusedGrantID *uuid.UUID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var refreshToken models.OAuthToken
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").
+			First(&refreshToken, "token_hash = ? AND client_id = ? AND kind = ?",
+				hashToken(requestDto.RefreshToken), client.ID, models.OAuthTokenRefresh).Error
+
+		if err != nil {
+			return &OAuthError{Code: oauthInvalidGrant, Description: "invalid refresh token"}
+		}
+
+		if refreshToken.RevokedAt != nil {
+			reusedGrantID = &refreshToken.GrantID
+			return &OAuthError{Code: oauthInvalidGrant, Description: "refresh token was already used, the grant was revoked"}
+		}
+
+		if time.Now().After(refreshToken.ExpiresAt) || refreshToken.User.DisabledAt != nil {
+			return &OAuthError{Code: oauthInvalidGrant, Description: "invalid refresh token"}
+		}
+
+		scopes := strings.Fields(refreshToken.Scopes)
+
+		// an app may narrow the scopes of the new access token, never widen them. The new refresh token keeps
+		// the scopes of the grant, RFC 6749 section 6
+		if requestDto.Scope != "" {
+			for _, scope := range strings.Fields(requestDto.Scope) {
+				if !containsString(scopes, scope) {
+					return &OAuthError{Code: oauthInvalidScope, Description: "scope was not granted: " + scope}
+				}
+			}
+
+			scopes = strings.Fields(requestDto.Scope)
+		}
+
+		if err := tx.Model(&refreshToken).Omit("Client", "User").Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		tokens, err = issueOAuthTokens(tx, client, refreshToken.UserID, refreshToken.GrantID, scopes, strings.Fields(refreshToken.Scopes))
+
+		return err
+	})
+
+	if reusedGrantID != nil {
+		revokeOAuthTokens(config.DB.Where("grant_id = ?", *reusedGrantID))
+	}
+
+	return tokens, err
+}
+
+// exchangeClientCredentials issues an access token with which a confidential app acts on behalf of the user that
+// registered it, limited to what the roles of that user grant
+func exchangeClientCredentials(client *models.OAuthClient, requestDto *dtos.TokenRequestDto) (*dtos.OAuthTokenDto, error) {
+
+	if !client.Confidential {
+		return nil, &OAuthError{Code: oauthUnauthorizedClient, Description: "only confidential apps can use the client credentials grant"}
+	}
+
+	var owner models.User
+
+	if err := config.DB.First(&owner, "id = ?", client.OwnerID).Error; err != nil {
+		return nil, err
+	}
+
+	if owner.DisabledAt != nil {
+		return nil, &OAuthError{Code: oauthInvalidGrant, Description: "the owner of the app is disabled"}
+	}
+
+	scopes, err := requestedScopes(client, requestDto.Scope)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if scopes, err = grantableScopes(owner.ID, scopes); err != nil {
+		return nil, err
+	}
+
+	return issueOAuthTokens(config.DB, client, owner.ID, uuid.New(), scopes, nil)
+}
+
+// issueOAuthTokens creates an access token of the grant, and a refresh token when refreshScopes is not nil
+func issueOAuthTokens(tx *gorm.DB, client *models.OAuthClient, userID uuid.UUID, grantID uuid.UUID, scopes []string, refreshScopes []string) (*dtos.OAuthTokenDto, error) {
+
+	accessToken, err := createOAuthToken(tx, client, userID, grantID, scopes, models.OAuthTokenAccess, oauthAccessTokenTTL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &dtos.OAuthTokenDto{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}
+
+	if refreshScopes != nil {
+		if tokens.RefreshToken, err = createOAuthToken(tx, client, userID, grantID, refreshScopes, models.OAuthTokenRefresh, refreshTokenTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+func createOAuthToken(tx *gorm.DB, client *models.OAuthClient, userID uuid.UUID, grantID uuid.UUID, scopes []string, kind string, ttl time.Duration) (string, error) {
+
+	token, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	if kind == models.OAuthTokenAccess {
+		token = oauthAccessTokenPrefix + token
+	} else {
+		token = oauthRefreshTokenPrefix + token
+	}
+
+	err = tx.Omit("Client", "User").Create(&models.OAuthToken{
+		ClientID:  client.ID,
+		UserID:    userID,
+		GrantID:   grantID,
+		Kind:      kind,
+		TokenHash: hashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+
+	return token, err
+}
+
+func revokeOAuthTokens(tokens *gorm.DB) error {
+	return tokens.Model(&models.OAuthToken{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+// authenticateOAuthClient checks the credentials of an app, public apps only identify themselves
+func authenticateOAuthClient(clientID string, clientSecret string) (*models.OAuthClient, error) {
+
+	invalidClient := &OAuthError{Code: oauthInvalidClient, Description: "app authentication failed"}
+
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	var client models.OAuthClient
+
+	if err := config.DB.First(&client, "client_id = ?", clientID).Error; err != nil {
+		return nil, invalidClient
+	}
+
+	if client.Confidential != (clientSecret != "") {
+		return nil, invalidClient
+	}
+
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, invalidClient
+	}
+
+	return &client, nil
+}
+
+// requestedScopes returns the space delimited scopes an app asks for, all of its scopes when it asks for none
+func requestedScopes(client *models.OAuthClient, scope string) ([]string, error) {
+
+	clientScopes := strings.Fields(client.Scopes)
+
+	if strings.TrimSpace(scope) == "" {
+		return clientScopes, nil
+	}
+
+	scopes := strings.Fields(scope)
+
+	for _, requested := range scopes {
+		if !containsString(clientScopes, requested) {
+			return nil, &OAuthError{Code: oauthInvalidScope, Description: "the app cannot ask for scope: " + requested}
+		}
+	}
+
+	return scopes, nil
+}
+
+// grantableScopes returns the scopes the roles of the user grant, an app cannot get more access than its user has
+func grantableScopes(userID uuid.UUID, scopes []string) ([]string, error) {
+
+	roles, err := loadUserRoles(config.DB, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := rolePermissionNames(roles)
+	granted := []string{}
+
+	for _, scope := range scopes {
+		if containsString(permissions, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if len(granted) == 0 {
+		return nil, &OAuthError{Code: oauthInvalidScope, Description: "none of the requested scopes are granted to the roles of the user"}
+	}
+
+	return granted, nil
+}
+
+// appendQuery adds parameters to a redirect URI, keeping the query it was registered with
+func appendQuery(redirectURI string, query url.Values) string {
+
+	parsed, err := url.Parse(redirectURI)
+
+	if err != nil {
+		return redirectURI
+	}
+
+	merged := parsed.Query()
+
+	for name, values := range query {
+		merged[name] = values
+	}
+
+	parsed.RawQuery = merged.Encode()
+
+	return parsed.String()
+}
+
+// oauthServiceError maps protocol errors to their status, RFC 6749 section 5.2: 401 for failed app authentication,
+// 400 for everything else the app did wrong
+func oauthServiceError(err error) *interfaces.ServiceError {
+
+	var oauthError *OAuthError
+
+	if !errors.As(err, &oauthError) {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	statusCode := 400
+
+	if oauthError.Code == oauthInvalidClient {
+		statusCode = 401
+	}
+
+	return &interfaces.ServiceError{
+		Error:      oauthError,
+		StatusCode: statusCode,
+	}
+}
+
+// authorizationServiceError maps errors of authorization requests that cannot be sent back to the app, they are
+// shown to the user instead, so a failed app lookup is a 400 and not an authentication failure
+func authorizationServiceError(err error) *interfaces.ServiceError {
+
+	var oauthError *OAuthError
+
+	if !errors.As(err, &oauthError) {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return &interfaces.ServiceError{
+		Error:      oauthError,
+		StatusCode: 400,
+	}
+}
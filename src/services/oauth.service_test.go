@@ -0,0 +1,170 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// the example of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "matching verifier", verifier: verifier, challenge: challenge, want: true},
+		{name: "other verifier", verifier: verifier[:len(verifier)-1] + "j", challenge: challenge, want: false},
+		{name: "plain method", verifier: verifier, challenge: verifier, want: false},
+		{name: "padded challenge", verifier: verifier, challenge: challenge + "=", want: false},
+		{name: "empty verifier", verifier: "", challenge: challenge, want: false},
+		{name: "empty challenge", verifier: verifier, challenge: "", want: false},
+	}
+
+	for _, test := range tests {
+		if got := verifyCodeChallenge(test.verifier, test.challenge); got != test.want {
+			t.Errorf("%s: verifyCodeChallenge = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPkcePattern(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", want: true},
+		{value: "abcdefghijklmnopqrstuvwxyz0123456789-._~ABCDEFG", want: true},
+		{value: "too-short", want: false},
+		{value: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjX+", want: false},
+		{value: string(make([]byte, 129)), want: false},
+	}
+
+	for _, test := range tests {
+		if got := pkcePattern.MatchString(test.value); got != test.want {
+			t.Errorf("pkcePattern.MatchString(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	tests := []struct {
+		redirectURI string
+		want        url.Values
+	}{
+		{redirectURI: "https://app.example.com/callback", want: url.Values{"code": {"abc"}, "state": {"xyz"}}},
+		{redirectURI: "https://app.example.com/callback?tenant=1", want: url.Values{"code": {"abc"}, "state": {"xyz"}, "tenant": {"1"}}},
+		// parameters of the response replace registered ones of the same name
+		{redirectURI: "https://app.example.com/callback?code=old", want: url.Values{"code": {"abc"}, "state": {"xyz"}}},
+	}
+
+	for _, test := range tests {
+		got, err := url.Parse(appendQuery(test.redirectURI, url.Values{"code": {"abc"}, "state": {"xyz"}}))
+		if err != nil {
+			t.Fatalf("%s: %v", test.redirectURI, err)
+		}
+
+		if got.Query().Encode() != test.want.Encode() {
+			t.Errorf("%s: query = %s, want %s", test.redirectURI, got.Query().Encode(), test.want.Encode())
+		}
+	}
+}
+
+func TestCheckAuthorizationCode(t *testing.T) {
+	now := time.Now()
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	redirectURI := "https://app.example.com/callback"
+
+	tests := []struct {
+		name             string
+		redirectURIGiven bool
+		expiresAt        time.Time
+		request          dtos.TokenRequestDto
+		wantCode         string
+	}{
+		{
+			name:             "named redirect URI named again",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(time.Minute),
+			request:          dtos.TokenRequestDto{RedirectURI: redirectURI, CodeVerifier: verifier},
+		},
+		{
+			name:             "named redirect URI left out",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(time.Minute),
+			request:          dtos.TokenRequestDto{CodeVerifier: verifier},
+			wantCode:         oauthInvalidGrant,
+		},
+		{
+			name:             "named redirect URI replaced",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(time.Minute),
+			request:          dtos.TokenRequestDto{RedirectURI: "https://evil.example.com/callback", CodeVerifier: verifier},
+			wantCode:         oauthInvalidGrant,
+		},
+		{
+			name:      "default redirect URI left out",
+			expiresAt: now.Add(time.Minute),
+			request:   dtos.TokenRequestDto{CodeVerifier: verifier},
+		},
+		{
+			name:      "default redirect URI named",
+			expiresAt: now.Add(time.Minute),
+			request:   dtos.TokenRequestDto{RedirectURI: redirectURI, CodeVerifier: verifier},
+		},
+		{
+			name:             "expired code",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(-time.Second),
+			request:          dtos.TokenRequestDto{RedirectURI: redirectURI, CodeVerifier: verifier},
+			wantCode:         oauthInvalidGrant,
+		},
+		{
+			name:             "missing code verifier",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(time.Minute),
+			request:          dtos.TokenRequestDto{RedirectURI: redirectURI},
+			wantCode:         oauthInvalidRequest,
+		},
+		{
+			name:             "wrong code verifier",
+			redirectURIGiven: true,
+			expiresAt:        now.Add(time.Minute),
+			request:          dtos.TokenRequestDto{RedirectURI: redirectURI, CodeVerifier: strings.Repeat("a", 43)},
+			wantCode:         oauthInvalidGrant,
+		},
+	}
+
+	for _, test := range tests {
+		code := &models.OAuthAuthorizationCode{
+			RedirectURI:      redirectURI,
+			RedirectURIGiven: test.redirectURIGiven,
+			CodeChallenge:    challenge,
+			ExpiresAt:        test.expiresAt,
+		}
+
+		err := checkAuthorizationCode(code, &test.request, now)
+
+		if test.wantCode == "" {
+			if err != nil {
+				t.Errorf("%s: checkAuthorizationCode = %v, want nil", test.name, err)
+			}
+			continue
+		}
+
+		oauthError, ok := err.(*OAuthError)
+
+		if !ok || oauthError.Code != test.wantCode {
+			t.Errorf("%s: checkAuthorizationCode = %v, want %s", test.name, err, test.wantCode)
+		}
+	}
+}
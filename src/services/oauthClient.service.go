@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+)
+
+// CreateOAuthClient registers a third party app of the authenticated user. An app can ask for no more scopes
+// than the roles of the user grant, confidential apps get a secret that is only returned once
+func CreateOAuthClient(context *gin.Context, createDto *dtos.CreateOAuthClientDto) (*dtos.CreatedOAuthClientDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if principal.Delegated() {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("personal access tokens and app tokens cannot register apps"),
+			StatusCode: 403,
+		}
+	}
+
+	roles, err := loadUserRoles(config.DB, principal.UserID)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	permissions := rolePermissionNames(roles)
+
+	for _, scope := range createDto.Scopes {
+		if !isKnownPermission(scope) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("unknown scope: " + scope),
+				StatusCode: 400,
+			}
+		}
+
+		if !containsString(permissions, scope) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("scope is not granted to your roles: " + scope),
+				StatusCode: 403,
+			}
+		}
+	}
+
+	// only confidential apps can do without a redirect URI, they use the client credentials grant
+	if len(createDto.RedirectURIs) == 0 && !createDto.Confidential {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("public apps need at least one redirect URI"),
+			StatusCode: 400,
+		}
+	}
+
+	for _, redirectURI := range createDto.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, &interfaces.ServiceError{
+				Error:      err,
+				StatusCode: 400,
+			}
+		}
+	}
+
+	clientID, err := randomToken()
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	client := models.OAuthClient{
+		OwnerID:      principal.UserID,
+		Name:         createDto.Name,
+		ClientID:     clientID,
+		Confidential: createDto.Confidential,
+		RedirectURIs: strings.Join(createDto.RedirectURIs, " "),
+		Scopes:       strings.Join(createDto.Scopes, " "),
+	}
+
+	clientSecret := ""
+
+	if client.Confidential {
+		if clientSecret, err = randomToken(); err != nil {
+			return nil, &interfaces.ServiceError{
+				Error:      err,
+				StatusCode: 500,
+			}
+		}
+
+		client.SecretHash = hashToken(clientSecret)
+	}
+
+	if err := config.DB.Omit("Owner").Create(&client).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return &dtos.CreatedOAuthClientDto{
+		OAuthClientDto: *oauthClientDto(&client),
+		ClientSecret:   clientSecret,
+	}, nil
+}
+
+// GetOAuthClients returns the apps the authenticated user registered, newest first
+func GetOAuthClients(context *gin.Context) ([]*dtos.OAuthClientDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var clients []*models.OAuthClient
+
+	if err := config.DB.Where("owner_id = ?", principal.UserID).Order("created_at DESC").Find(&clients).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	returnClients := []*dtos.OAuthClientDto{}
+
+	for _, client := range clients {
+		returnClients = append(returnClients, oauthClientDto(client))
+	}
+
+	return returnClients, nil
+}
+
+// DeleteOAuthClient removes an app of the authenticated user, together with its codes and tokens
+func DeleteOAuthClient(context *gin.Context, clientID string) *interfaces.ServiceError {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	result := config.DB.Where("id = ? AND owner_id = ?", clientID, principal.UserID).Delete(&models.OAuthClient{})
+
+	if result.Error != nil {
+		return &interfaces.ServiceError{
+			Error:      result.Error,
+			StatusCode: 500,
+		}
+	}
+
+	if result.RowsAffected == 0 {
+		return &interfaces.ServiceError{
+			Error:      errors.New("app not found"),
+			StatusCode: 404,
+		}
+	}
+
+	return nil
+}
+
+// validateRedirectURI only accepts https URIs, and plain http on the loopback interface for apps in development
+func validateRedirectURI(redirectURI string) error {
+
+	parsed, err := url.Parse(redirectURI)
+
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" || parsed.User != nil {
+		return errors.New("invalid redirect URI: " + redirectURI)
+	}
+
+	hostname := parsed.Hostname()
+	loopback := hostname == "localhost" || (net.ParseIP(hostname) != nil && net.ParseIP(hostname).IsLoopback())
+
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && loopback) {
+		return errors.New("redirect URIs must use https: " + redirectURI)
+	}
+
+	return nil
+}
+
+func oauthClientDto(client *models.OAuthClient) *dtos.OAuthClientDto {
+	return &dtos.OAuthClientDto{
+		ID:           client.ID.String(),
+		Name:         client.Name,
+		ClientID:     client.ClientID,
+		Confidential: client.Confidential,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		Scopes:       strings.Fields(client.Scopes),
+		CreatedAt:    client.CreatedAt,
+	}
+}
@@ -0,0 +1,536 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	errUnknownOidcProvider = errors.New("unknown identity provider")
+	errInvalidOidcState    = errors.New("invalid or expired login state")
+	errOidcEmailUnverified = errors.New("the identity provider did not verify the email address")
+)
+
+// oidcProvider is an OpenID Connect provider configured with OIDC_<NAME>_* environment variables,
+// its endpoints and keys come from the discovery document of the issuer
+type oidcProvider struct {
+	name         string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	// roleClaim names the ID token claim holding the groups or roles of the user, roleMapping maps
+	// its values to local roles and users with one of the adminValues in it become admins
+	roleClaim   string
+	roleMapping map[string]string
+	adminValues []string
+	provider    *oidc.Provider
+}
+
+// discovered providers are kept, a failed discovery is retried on the next login
+var oidcProviders = struct {
+	sync.Mutex
+	loaded map[string]*oidcProvider
+}{loaded: map[string]*oidcProvider{}}
+
+// OidcLogin is the outcome of a login at an identity provider
+type OidcLogin struct {
+	User *models.User
+	// MfaDone is set when the provider reports that it checked a second factor
+	MfaDone bool
+}
+
+// StartOidcLogin returns the authorization URL of the provider to redirect the browser to,
+// together with the state the callback has to come back with
+func StartOidcLogin(providerName string) (string, string, *interfaces.ServiceError) {
+
+	provider, err := loadOidcProvider(providerName)
+
+	if err != nil {
+		return "", "", oidcServiceError(err)
+	}
+
+	state, err := randomToken()
+
+	if err != nil {
+		return "", "", oidcServiceError(err)
+	}
+
+	nonce, err := randomToken()
+
+	if err != nil {
+		return "", "", oidcServiceError(err)
+	}
+
+	// PKCE, the provider only hands out tokens to whoever knows the verifier behind the challenge
+	codeVerifier, err := randomToken()
+
+	if err != nil {
+		return "", "", oidcServiceError(err)
+	}
+
+	codeChallenge := sha256.Sum256([]byte(codeVerifier))
+
+	config.DB.Where("expires_at < ?", time.Now()).Delete(&models.OidcLoginState{})
+
+	err = config.DB.Create(&models.OidcLoginState{
+		Provider:     provider.name,
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}).Error
+
+	if err != nil {
+		return "", "", oidcServiceError(err)
+	}
+
+	authURL := provider.oauth2Config().AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(codeChallenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	return authURL, state, nil
+}
+
+// CompleteOidcLogin exchanges the authorization code for an ID token and returns the user it belongs to.
+// Unknown subjects are linked to the user with the same verified email, or get a new user
+func CompleteOidcLogin(ctx context.Context, providerName string, code string, state string) (*OidcLogin, *interfaces.ServiceError) {
+
+	provider, err := loadOidcProvider(providerName)
+
+	if err != nil {
+		return nil, oidcServiceError(err)
+	}
+
+	var loginState models.OidcLoginState
+
+	// the state is removed right away, so a callback cannot be replayed
+	result := config.DB.Clauses(clause.Returning{}).
+		Where("state_hash = ? AND provider = ?", hashToken(state), provider.name).
+		Delete(&loginState)
+
+	if result.Error != nil {
+		return nil, oidcServiceError(result.Error)
+	}
+
+	if result.RowsAffected == 0 || time.Now().After(loginState.ExpiresAt) {
+		return nil, oidcServiceError(errInvalidOidcState)
+	}
+
+	oauth2Token, err := provider.oauth2Config().Exchange(ctx, code,
+		oauth2.SetAuthURLParam("code_verifier", loginState.CodeVerifier))
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      fmt.Errorf("code exchange failed: %w", err),
+			StatusCode: 401,
+		}
+	}
+
+	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
+
+	if !ok {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("the identity provider returned no ID token"),
+			StatusCode: 401,
+		}
+	}
+
+	idToken, err := provider.provider.Verifier(&oidc.Config{ClientID: provider.clientID}).Verify(ctx, rawIDToken)
+
+	if err != nil || idToken.Nonce != loginState.Nonce {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("invalid ID token"),
+			StatusCode: 401,
+		}
+	}
+
+	var claims map[string]interface{}
+
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, oidcServiceError(err)
+	}
+
+	var user *models.User
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		user, err = findOrLinkOidcUser(tx, provider, idToken.Subject, claims)
+
+		if err != nil {
+			return err
+		}
+
+		return provider.syncRoles(tx, user, claims)
+	})
+
+	if err != nil {
+		return nil, oidcServiceError(err)
+	}
+
+	forgetTokenVersions(user.ID)
+
+	if serviceError := CheckAccountActive(user); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &OidcLogin{
+		User:    user,
+		MfaDone: claimContains(claims["amr"], "mfa"),
+	}, nil
+}
+
+// findOrLinkOidcUser returns the user of the subject, linking or creating the user on its first login
+func findOrLinkOidcUser(tx *gorm.DB, provider *oidcProvider, subject string, claims map[string]interface{}) (*models.User, error) {
+
+	var identity models.ExternalIdentity
+
+	err := tx.Preload("User").First(&identity, "provider = ? AND subject = ?", provider.name, subject).Error
+
+	if err == nil {
+		return &identity.User, nil
+	}
+
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	email, _ := claims["email"].(string)
+
+	// an unverified email could belong to someone else, linking it would hand over that account
+	if email == "" || !claimIsTrue(claims["email_verified"]) {
+		return nil, errOidcEmailUnverified
+	}
+
+	var user models.User
+
+	err = tx.First(&user, "lower(email) = ?", normalizeEmail(email)).Error
+
+	if err == gorm.ErrRecordNotFound {
+		// the user signs in at the provider, the local password is random and unknown to anyone
+		password, err := randomToken()
+
+		if err != nil {
+			return nil, err
+		}
+
+		firstName, _ := claims["given_name"].(string)
+		lastName, _ := claims["family_name"].(string)
+
+		if firstName == "" && lastName == "" {
+			name, _ := claims["name"].(string)
+			firstName, lastName, _ = strings.Cut(name, " ")
+		}
+
+		user = models.User{
+			Email:     email,
+			Password:  password,
+			FirstName: firstName,
+			LastName:  lastName,
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+
+		if err := assignDefaultRole(tx, &user); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if user.EmailVerifiedAt == nil {
+		// whoever registered the unverified account never proved to own the email, it may have been
+		// created ahead of time to take over the account once the owner signs in with the provider
+		if err := resetUnverifiedAccount(tx, &user); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+
+		if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return nil, err
+		}
+
+		user.EmailVerifiedAt = &now
+	}
+
+	err = tx.Omit("User").Create(&models.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: provider.name,
+		Subject:  subject,
+		Email:    email,
+	}).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:  models.AuditIdentityLinked,
+		UserID: &user.ID,
+		Email:  normalizeEmail(email),
+		Detail: provider.name,
+	})
+
+	return &user, nil
+}
+
+// resetUnverifiedAccount removes every way into an account that was registered with an email nobody verified,
+// its password is replaced by a random one and all of its logins, tokens and second factors are revoked
+func resetUnverifiedAccount(tx *gorm.DB, user *models.User) error {
+
+	password, err := randomToken()
+
+	if err != nil {
+		return err
+	}
+
+	if err := setPassword(tx, user.ID, password); err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", time.Now()).Error
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(user).Updates(map[string]interface{}{
+		"totp_secret":     nil,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error
+
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("user_id = ?", user.ID).Delete(&models.Passkey{}).Error
+}
+
+// syncRoles gives the user the roles its ID token claims map to, users without a mapped value get the default role.
+// Providers without a role claim leave roles alone
+func (provider *oidcProvider) syncRoles(tx *gorm.DB, user *models.User, claims map[string]interface{}) error {
+
+	if provider.roleClaim == "" {
+		return nil
+	}
+
+	names := []string{}
+
+	for value, role := range provider.roleMapping {
+		if claimContains(claims[provider.roleClaim], value) && !containsString(names, role) {
+			names = append(names, role)
+		}
+	}
+
+	for _, adminValue := range provider.adminValues {
+		if claimContains(claims[provider.roleClaim], adminValue) && !containsString(names, models.RoleAdmin) {
+			names = append(names, models.RoleAdmin)
+		}
+	}
+
+	if len(names) == 0 {
+		names = append(names, defaultRole)
+	}
+
+	current, err := loadUserRoles(tx, user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+	currentNames := roleNames(current)
+	sort.Strings(currentNames)
+
+	if strings.Join(currentNames, " ") == strings.Join(names, " ") {
+		return nil
+	}
+
+	roles, err := findRoles(tx, names)
+
+	if err != nil {
+		return fmt.Errorf("role mapping of %s: %w", provider.name, err)
+	}
+
+	if err := assignRoles(tx, user, roles); err != nil {
+		return err
+	}
+
+	return InvalidateUserTokens(tx, user.ID)
+}
+
+func (provider *oidcProvider) oauth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.clientID,
+		ClientSecret: provider.clientSecret,
+		Endpoint:     provider.provider.Endpoint(),
+		RedirectURL:  provider.redirectURL,
+		Scopes:       provider.scopes,
+	}
+}
+
+// loadOidcProvider returns a provider listed in OIDC_PROVIDERS, fetching its discovery document on first use
+func loadOidcProvider(name string) (*oidcProvider, error) {
+
+	name = strings.ToLower(name)
+
+	if !claimContains(strings.Fields(strings.ToLower(os.Getenv("OIDC_PROVIDERS"))), name) {
+		return nil, errUnknownOidcProvider
+	}
+
+	oidcProviders.Lock()
+	defer oidcProviders.Unlock()
+
+	if provider, ok := oidcProviders.loaded[name]; ok {
+		return provider, nil
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+	issuer := os.Getenv(prefix + "ISSUER")
+	clientID := os.Getenv(prefix + "CLIENT_ID")
+
+	if issuer == "" || clientID == "" {
+		return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+	}
+
+	// the provider keeps the context to fetch its signing keys later on, so it must outlive the request
+	discoveryContext := oidc.ClientContext(context.Background(), &http.Client{Timeout: 10 * time.Second})
+
+	discovered, err := oidc.NewProvider(discoveryContext, issuer)
+
+	if err != nil {
+		return nil, fmt.Errorf("discovery of %s failed: %w", name, err)
+	}
+
+	scopes := strings.Fields(os.Getenv(prefix + "SCOPES"))
+
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	redirectURL := os.Getenv(prefix + "REDIRECT_URL")
+
+	if redirectURL == "" {
+		redirectURL = os.Getenv("API_URL") + "/auth/oidc/" + name + "/callback"
+	}
+
+	provider := &oidcProvider{
+		name:         name,
+		clientID:     clientID,
+		clientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		roleClaim:    os.Getenv(prefix + "ROLE_CLAIM"),
+		roleMapping:  parseRoleMapping(os.Getenv(prefix + "ROLE_MAPPING")),
+		adminValues:  strings.Fields(os.Getenv(prefix + "ADMIN_VALUES")),
+		provider:     discovered,
+	}
+
+	oidcProviders.loaded[name] = provider
+
+	return provider, nil
+}
+
+// parseRoleMapping reads space separated value=role pairs, e.g. "editors=catalog_editor mods=moderator"
+func parseRoleMapping(mapping string) map[string]string {
+
+	roleMapping := map[string]string{}
+
+	for _, pair := range strings.Fields(mapping) {
+		if value, role, ok := strings.Cut(pair, "="); ok && value != "" && role != "" {
+			roleMapping[value] = role
+		}
+	}
+
+	return roleMapping
+}
+
+// claimContains reports whether a string claim equals the value, or a list claim contains it
+func claimContains(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []string:
+		for _, item := range claim {
+			if item == value {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range claim {
+			if item == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// claimIsTrue accepts booleans and, as some providers send them, "true" strings
+func claimIsTrue(claim interface{}) bool {
+	switch claim := claim.(type) {
+	case bool:
+		return claim
+	case string:
+		return claim == "true"
+	}
+
+	return false
+}
+
+func oidcServiceError(err error) *interfaces.ServiceError {
+	switch err {
+	case errUnknownOidcProvider:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	case errInvalidOidcState:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	case errOidcEmailUnverified:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 403,
+		}
+	default:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"github.com/jaimy-monsuur/movie-api/src/webauthn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const passkeyChallengeTTL = 5 * time.Minute
+
+var (
+	errInvalidPasskeyChallenge = errors.New("invalid or expired passkey challenge")
+	errUnknownPasskey          = errors.New("unknown passkey")
+)
+
+// StartPasskeyRegistration returns the options the browser needs to create a passkey for the authenticated user
+func StartPasskeyRegistration(context *gin.Context) (*webauthn.CreationOptions, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	// a leaked token must not be able to add a way to log in
+	if principal.Delegated() {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("personal access tokens and app tokens cannot register passkeys"),
+			StatusCode: 403,
+		}
+	}
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		return nil, serviceError
+	}
+
+	passkeys, err := findPasskeys(user.ID)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	exclude := []webauthn.CredentialDescriptor{}
+
+	for _, passkey := range passkeys {
+		exclude = append(exclude, webauthn.NewCredentialDescriptor(passkey.CredentialID, strings.Fields(passkey.Transports)))
+	}
+
+	challenge, err := createPasskeyChallenge(models.PasskeyChallengeRegistration, &user.ID)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	return config.RelyingParty.CreationOptions(challenge, user.ID[:], user.Email, strings.TrimSpace(user.FirstName+" "+user.LastName), exclude), nil
+}
+
+// FinishPasskeyRegistration verifies the passkey the browser created and stores it with the authenticated user
+func FinishPasskeyRegistration(context *gin.Context, registerDto *dtos.RegisterPasskeyDto) (*dtos.PasskeyDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if principal.Delegated() {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("personal access tokens and app tokens cannot register passkeys"),
+			StatusCode: 403,
+		}
+	}
+
+	challenge, err := webauthn.ChallengeOf(registerDto.Credential.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	if _, err := consumePasskeyChallenge(challenge, models.PasskeyChallengeRegistration, &principal.UserID); err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	credential, err := config.RelyingParty.VerifyRegistration(&registerDto.Credential, challenge)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	passkey := models.Passkey{
+		UserID:         principal.UserID,
+		Name:           registerDto.Name,
+		CredentialID:   credential.ID,
+		PublicKey:      credential.PublicKey,
+		SignCount:      int64(credential.SignCount),
+		AAGUID:         credential.AAGUID,
+		Transports:     strings.Join(credential.Transports, " "),
+		BackupEligible: credential.BackupEligible,
+		BackedUp:       credential.BackedUp,
+	}
+
+	if err := config.DB.Omit("User").Create(&passkey).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("this passkey is already registered"),
+				StatusCode: 409,
+			}
+		}
+
+		return nil, passkeyServiceError(err)
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditPasskeyAdded,
+		UserID:    &principal.UserID,
+		Email:     normalizeEmail(principal.Email),
+		IPAddress: context.ClientIP(),
+		Detail:    passkey.Name,
+	})
+
+	return passkeyDto(&passkey), nil
+}
+
+// GetPasskeys returns all passkeys of the authenticated user, newest first
+func GetPasskeys(context *gin.Context) ([]*dtos.PasskeyDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	passkeys, err := findPasskeys(principal.UserID)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	returnPasskeys := []*dtos.PasskeyDto{}
+
+	for _, passkey := range passkeys {
+		returnPasskeys = append(returnPasskeys, passkeyDto(passkey))
+	}
+
+	return returnPasskeys, nil
+}
+
+// DeletePasskey removes a passkey of the authenticated user, it cannot be used to log in anymore
+func DeletePasskey(context *gin.Context, passkeyID string) *interfaces.ServiceError {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var passkey models.Passkey
+
+	result := config.DB.Clauses(clause.Returning{}).
+		Where("id = ? AND user_id = ?", passkeyID, principal.UserID).
+		Delete(&passkey)
+
+	if result.Error != nil {
+		return passkeyServiceError(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return &interfaces.ServiceError{
+			Error:      errors.New("passkey not found"),
+			StatusCode: 404,
+		}
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditPasskeyRemoved,
+		UserID:    &principal.UserID,
+		Email:     normalizeEmail(principal.Email),
+		IPAddress: context.ClientIP(),
+		Detail:    passkey.Name,
+	})
+
+	return nil
+}
+
+// StartPasskeyLogin returns the options the browser needs to log in with a passkey. With an email only the
+// passkeys of that user are allowed, otherwise the browser offers every passkey it has for this API
+func StartPasskeyLogin(optionsDto *dtos.PasskeyLoginOptionsDto) (*webauthn.RequestOptions, *interfaces.ServiceError) {
+
+	allow := []webauthn.CredentialDescriptor{}
+	var userID *uuid.UUID
+
+	if optionsDto.Email != "" {
+		if user, err := GetUserByEmail(optionsDto.Email); err == nil {
+			passkeys, err := findPasskeys(user.ID)
+
+			if err != nil {
+				return nil, passkeyServiceError(err)
+			}
+
+			for _, passkey := range passkeys {
+				allow = append(allow, webauthn.NewCredentialDescriptor(passkey.CredentialID, strings.Fields(passkey.Transports)))
+			}
+
+			userID = &user.ID
+		}
+	}
+
+	challenge, err := createPasskeyChallenge(models.PasskeyChallengeLogin, userID)
+
+	if err != nil {
+		return nil, passkeyServiceError(err)
+	}
+
+	return config.RelyingParty.RequestOptions(challenge, allow), nil
+}
+
+// FinishPasskeyLogin verifies the signed challenge and returns the user of the passkey. The authenticator verified
+// the user with a PIN or biometrics, so a passkey login counts as a login with a second factor
+func FinishPasskeyLogin(loginDto *dtos.PasskeyLoginDto) (*models.User, *interfaces.ServiceError) {
+
+	challenge, err := webauthn.ChallengeOf(loginDto.Credential.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, passkeyLoginError(err)
+	}
+
+	passkeyChallenge, err := consumePasskeyChallenge(challenge, models.PasskeyChallengeLogin, nil)
+
+	if err != nil {
+		return nil, passkeyLoginError(err)
+	}
+
+	credentialID, err := loginDto.Credential.CredentialID()
+
+	if err != nil {
+		return nil, passkeyLoginError(err)
+	}
+
+	var passkey models.Passkey
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// the row is locked, so two logins with the same assertion cannot both pass the sign count check
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").
+			First(&passkey, "credential_id = ?", credentialID).Error
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUnknownPasskey
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if passkeyChallenge.UserID != nil && *passkeyChallenge.UserID != passkey.UserID {
+			return errUnknownPasskey
+		}
+
+		assertion, err := config.RelyingParty.VerifyAssertion(&loginDto.Credential, challenge,
+			passkey.PublicKey, passkey.UserID[:], uint32(passkey.SignCount))
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&passkey).Omit("User").Updates(map[string]interface{}{
+			"sign_count":   int64(assertion.SignCount),
+			"backed_up":    assertion.BackedUp,
+			"last_used_at": time.Now(),
+		}).Error
+	})
+
+	if errors.Is(err, webauthn.ErrSignCountRegression) {
+		recordAudit(&models.AuditLog{
+			Event:  models.AuditLoginBlocked,
+			UserID: &passkey.UserID,
+			Email:  normalizeEmail(passkey.User.Email),
+			Detail: "sign counter of passkey " + passkey.Name + " did not increase, it may be cloned",
+		})
+	}
+
+	if err != nil {
+		return nil, passkeyLoginError(err)
+	}
+
+	if serviceError := CheckAccountActive(&passkey.User); serviceError != nil {
+		return nil, serviceError
+	}
+
+	return &passkey.User, nil
+}
+
+func createPasskeyChallenge(purpose string, userID *uuid.UUID) (string, error) {
+
+	challenge, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	config.DB.Where("expires_at < ?", time.Now()).Delete(&models.PasskeyChallenge{})
+
+	err = config.DB.Create(&models.PasskeyChallenge{
+		Purpose:       purpose,
+		UserID:        userID,
+		ChallengeHash: hashToken(challenge),
+		ExpiresAt:     time.Now().Add(passkeyChallengeTTL),
+	}).Error
+
+	return challenge, err
+}
+
+// consumePasskeyChallenge removes the challenge right away, so a response cannot be replayed.
+// A registration challenge only belongs to the user that started it
+func consumePasskeyChallenge(challenge string, purpose string, userID *uuid.UUID) (*models.PasskeyChallenge, error) {
+
+	var passkeyChallenge models.PasskeyChallenge
+
+	query := config.DB.Clauses(clause.Returning{}).Where("challenge_hash = ? AND purpose = ?", hashToken(challenge), purpose)
+
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	result := query.Delete(&passkeyChallenge)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || time.Now().After(passkeyChallenge.ExpiresAt) {
+		return nil, errInvalidPasskeyChallenge
+	}
+
+	return &passkeyChallenge, nil
+}
+
+func findPasskeys(userID uuid.UUID) ([]*models.Passkey, error) {
+
+	var passkeys []*models.Passkey
+
+	err := config.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&passkeys).Error
+
+	return passkeys, err
+}
+
+func passkeyDto(passkey *models.Passkey) *dtos.PasskeyDto {
+	return &dtos.PasskeyDto{
+		ID:         passkey.ID.String(),
+		Name:       passkey.Name,
+		Transports: strings.Fields(passkey.Transports),
+		BackedUp:   passkey.BackedUp,
+		CreatedAt:  passkey.CreatedAt,
+		LastUsedAt: passkey.LastUsedAt,
+	}
+}
+
+func passkeyServiceError(err error) *interfaces.ServiceError {
+
+	statusCode := 500
+
+	switch {
+	case errors.Is(err, webauthn.ErrVerification):
+		statusCode = 400
+	case err == errInvalidPasskeyChallenge || err == errUnknownPasskey:
+		statusCode = 401
+	}
+
+	return &interfaces.ServiceError{
+		Error:      err,
+		StatusCode: statusCode,
+	}
+}
+
+// passkeyLoginError answers every response that does not check out with 401, like a wrong password
+func passkeyLoginError(err error) *interfaces.ServiceError {
+
+	serviceError := passkeyServiceError(err)
+
+	if serviceError.StatusCode == 400 {
+		serviceError.StatusCode = 401
+	}
+
+	return serviceError
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/mailer"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = time.Hour
+
+// RequestPasswordReset mails a reset link when a user with the email exists, callers always
+// get the same answer so the endpoint cannot be used to find registered emails
+func RequestPasswordReset(email string) {
+
+	user, err := GetUserByEmail(email)
+
+	if err != nil || userTokenCreatedWithin(user.ID, models.UserTokenPasswordReset, userTokenResendInterval) {
+		return
+	}
+
+	sendPasswordResetEmail(user, "Open the link below to choose a new password, it expires in 1 hour.\n"+
+		"If you did not ask for a password reset you can ignore this email.\n")
+}
+
+// ForcePasswordReset makes a user choose a new password, its logins are ended, password logins are refused
+// until the reset and a reset link is mailed
+func ForcePasswordReset(context *gin.Context, userID string) *interfaces.ServiceError {
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password_reset_required", true).Error; err != nil {
+			return err
+		}
+
+		if err := revokeRefreshTokens(tx.Where("user_id = ?", user.ID)); err != nil {
+			return err
+		}
+
+		if err := revokeDelegatedTokens(tx, user.ID); err != nil {
+			return err
+		}
+
+		return InvalidateUserTokens(tx, user.ID)
+	})
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	forgetTokenVersions(user.ID)
+
+	entry := &models.AuditLog{
+		Event:  models.AuditPasswordResetForced,
+		UserID: &user.ID,
+		Email:  normalizeEmail(user.Email),
+	}
+
+	if principal, err := GetPrincipal(context); err == nil {
+		entry.ActorID = &principal.UserID
+	}
+
+	recordAudit(entry)
+
+	sendPasswordResetEmail(&user, "Your password has to be changed before you can log in with it again.\n"+
+		"Open the link below to choose a new password, it expires in 1 hour.\n")
+
+	return nil
+}
+
+// sendPasswordResetEmail mails a reset link, explanation tells why it was sent
+func sendPasswordResetEmail(user *models.User, explanation string) {
+
+	token, err := createUserToken(config.DB, user.ID, models.UserTokenPasswordReset, passwordResetTTL)
+
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %s: %v", user.ID, err)
+		return
+	}
+
+	err = config.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    "Hi " + user.FirstName + ",\n\n" + explanation + "\n" + appLink("/reset-password", token) + "\n",
+	})
+
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password with a token from a reset email and logs the user out everywhere
+func ResetPassword(resetPasswordDto *dtos.ResetPasswordDto) *interfaces.ServiceError {
+
+	var user models.User
+
+	// the policy needs the user, the token is only used up once the password is accepted
+	userToken, err := findUserToken(config.DB, resetPasswordDto.Token, models.UserTokenPasswordReset)
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	if err := config.DB.First(&user, "id = ?", userToken.UserID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      errInvalidUserToken,
+			StatusCode: 400,
+		}
+	}
+
+	if serviceError := checkPasswordPolicy("password", resetPasswordDto.Password, &user); serviceError != nil {
+		return serviceError
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, resetPasswordDto.Token, models.UserTokenPasswordReset)
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, "id = ?", userToken.UserID).Error; err != nil {
+			return err
+		}
+
+		// the reset link was opened from the inbox, which proves ownership of the address as well
+		if user.EmailVerifiedAt == nil {
+			if err := tx.Model(&user).Update("email_verified_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+
+		return setPassword(tx, user.ID, resetPasswordDto.Password)
+	})
+
+	if err == errInvalidUserToken {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	forgetTokenVersions(user.ID)
+
+	sendPasswordChangedEmail(&user)
+
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one, every other login of the user
+// is ended and new tokens are returned so the caller stays logged in
+func ChangePassword(context *gin.Context, userID string, changePasswordDto *dtos.ChangePasswordDto) (*dtos.TokenDto, *interfaces.ServiceError) {
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	if err := CheckUser(context, user.ID.String()); err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if err := user.ComparePassword(changePasswordDto.CurrentPassword); err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("current password is incorrect"),
+			StatusCode: 403,
+		}
+	}
+
+	if serviceError := checkPasswordPolicy("newPassword", changePasswordDto.NewPassword, &user); serviceError != nil {
+		return nil, serviceError
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, user.ID, changePasswordDto.NewPassword)
+	})
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	forgetTokenVersions(user.ID)
+
+	sendPasswordChangedEmail(&user)
+
+	// reload the user so the new token carries the bumped token version
+	if err := config.DB.First(&user, "id = ?", user.ID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	tokens, err := IssueTokens(context, &user)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return tokens, nil
+}
+
+// setPassword stores the hash of a new password and revokes every token of the user
+func setPassword(tx *gorm.DB, userID uuid.UUID, password string) error {
+
+	passwordHash, err := models.HashPassword(password)
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password":                passwordHash,
+		"password_reset_required": false,
+	}).Error
+
+	if err != nil {
+		return err
+	}
+
+	// reset links sent before the change must not work anymore
+	err = tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, models.UserTokenPasswordReset).
+		Update("used_at", time.Now()).Error
+
+	if err != nil {
+		return err
+	}
+
+	if err := revokeRefreshTokens(tx.Where("user_id = ?", userID)); err != nil {
+		return err
+	}
+
+	if err := revokeDelegatedTokens(tx, userID); err != nil {
+		return err
+	}
+
+	return InvalidateUserTokens(tx, userID)
+}
+
+// revokeDelegatedTokens revokes the personal access tokens and app tokens of a user, they do not carry the token
+// version, and whoever knew the old password may have created them
+func revokeDelegatedTokens(tx *gorm.DB, userID uuid.UUID) error {
+
+	err := tx.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+
+	if err != nil {
+		return err
+	}
+
+	return revokeOAuthTokens(tx.Where("user_id = ?", userID))
+}
+
+// checkPasswordPolicy fails with the policy violations of a password as errors of the request field
+func checkPasswordPolicy(field string, password string, user *models.User) *interfaces.ServiceError {
+
+	violations := config.PasswordPolicy.Check(password, user.Email, user.FirstName, user.LastName)
+
+	if violations == nil {
+		return nil
+	}
+
+	return &interfaces.ServiceError{
+		Error:       errors.New(field + " " + strings.Join(violations, ", ")),
+		StatusCode:  400,
+		FieldErrors: map[string][]string{field: violations},
+	}
+}
+
+// sendPasswordChangedEmail tells the user about the change, so an unexpected change does not go unnoticed
+func sendPasswordChangedEmail(user *models.User) {
+
+	err := config.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"The password of your account was just changed and all devices were logged out.\n" +
+			"If you did not do this, reset your password right away.\n",
+	})
+
+	if err != nil {
+		log.Printf("Failed to send password changed email to user %s: %v", user.ID, err)
+	}
+}
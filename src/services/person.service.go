@@ -0,0 +1,120 @@
+package services
+
+import (
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const defaultPeoplePageLimit = 20
+
+func GetAllPeople(query dtos.PeopleQueryDto) ([]*dtos.PersonDto, *dtos.PaginationDto, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultPeoplePageLimit
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	var total int64
+
+	if err := filterPeople(config.DB.Model(&models.Person{}), query).Count(&total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var people []*models.Person
+
+	err := filterPeople(config.DB, query).Order("name").Order("id").Limit(query.Limit).Offset((query.Page - 1) * query.Limit).Find(&people).Error
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	returnPeople := []*dtos.PersonDto{}
+
+	for _, person := range people {
+		returnPeople = append(returnPeople, &dtos.PersonDto{
+			ID:   person.ID.String(),
+			Name: person.Name,
+		})
+	}
+
+	pagination := &dtos.PaginationDto{
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+
+	return returnPeople, pagination, nil
+}
+
+func filterPeople(db *gorm.DB, query dtos.PeopleQueryDto) *gorm.DB {
+	if query.Name != "" {
+		db = db.Where("name ILIKE ?", "%"+query.Name+"%")
+	}
+
+	return db
+}
+
+func GetPersonByID(ID string) (*dtos.PersonDto, error) {
+	var person models.Person
+
+	if err := config.DB.First(&person, "id = ?", ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.PersonDto{
+		ID:   person.ID.String(),
+		Name: person.Name,
+	}, nil
+}
+
+func GetFilmography(personID string) ([]*dtos.FilmographyEntryDto, error) {
+	if _, err := GetPersonByID(personID); err != nil {
+		return nil, err
+	}
+
+	var credits []*models.Credit
+
+	err := config.DB.Joins("Movie").
+		Where("credits.person_id = ?", personID).
+		Order(`"Movie".year DESC`).
+		Order(`"Movie".title`).
+		Find(&credits).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	filmography := []*dtos.FilmographyEntryDto{}
+
+	for _, credit := range credits {
+		filmography = append(filmography, &dtos.FilmographyEntryDto{
+			CreditID:   credit.ID.String(),
+			MovieID:    credit.MovieID.String(),
+			Title:      credit.Movie.Title,
+			Year:       credit.Movie.Year,
+			RoleType:   credit.RoleType,
+			Character:  credit.Character,
+			Department: credit.Department,
+			Job:        credit.Job,
+		})
+	}
+
+	return filmography, nil
+}
+
+// findOrCreatePerson returns the person with exactly the given name, creating it when there is none
+func findOrCreatePerson(tx *gorm.DB, name string) (*models.Person, error) {
+	var person models.Person
+
+	err := tx.Where(models.Person{Name: name}).FirstOrCreate(&person).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &person, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+)
+
+const (
+	// personal access tokens start with a prefix, so they are told apart from JWTs and easy to spot in leaked code
+	personalAccessTokenPrefix = "pat_"
+
+	defaultPersonalAccessTokenDays = 30
+
+	// last_used_at is written at most once per interval, not on every request
+	personalAccessTokenUsageInterval = time.Minute
+)
+
+var errInvalidPersonalAccessToken = errors.New("invalid personal access token")
+
+// CreatePersonalAccessToken creates a token for the authenticated user, limited to permissions its roles grant
+func CreatePersonalAccessToken(context *gin.Context, createDto *dtos.CreatePersonalAccessTokenDto) (*dtos.CreatedPersonalAccessTokenDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	// a leaked token must not be able to mint new tokens that outlive it
+	if principal.Delegated() {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("personal access tokens and app tokens cannot create personal access tokens"),
+			StatusCode: 403,
+		}
+	}
+
+	user, serviceError := principalUser(context)
+
+	if serviceError != nil {
+		return nil, serviceError
+	}
+
+	roles, err := loadUserRoles(config.DB, user.ID)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	permissions := rolePermissionNames(roles)
+
+	for _, scope := range createDto.Scopes {
+		if !isKnownPermission(scope) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("unknown scope: " + scope),
+				StatusCode: 400,
+			}
+		}
+
+		if !containsString(permissions, scope) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("scope is not granted to your roles: " + scope),
+				StatusCode: 403,
+			}
+		}
+	}
+
+	if createDto.ExpiresInDays == 0 {
+		createDto.ExpiresInDays = defaultPersonalAccessTokenDays
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	token = personalAccessTokenPrefix + token
+
+	storedToken := models.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      createDto.Name,
+		TokenHash: hashToken(token),
+		Scopes:    strings.Join(createDto.Scopes, " "),
+		ExpiresAt: time.Now().AddDate(0, 0, createDto.ExpiresInDays),
+	}
+
+	if err := config.DB.Omit("User").Create(&storedToken).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return &dtos.CreatedPersonalAccessTokenDto{
+		PersonalAccessTokenDto: *personalAccessTokenDto(&storedToken),
+		Token:                  token,
+	}, nil
+}
+
+// GetPersonalAccessTokens returns all tokens of the authenticated user, newest first
+func GetPersonalAccessTokens(context *gin.Context) ([]*dtos.PersonalAccessTokenDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var storedTokens []*models.PersonalAccessToken
+
+	err = config.DB.Where("user_id = ?", principal.UserID).Order("created_at DESC").Find(&storedTokens).Error
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	returnTokens := []*dtos.PersonalAccessTokenDto{}
+
+	for _, storedToken := range storedTokens {
+		returnTokens = append(returnTokens, personalAccessTokenDto(storedToken))
+	}
+
+	return returnTokens, nil
+}
+
+// RevokePersonalAccessToken revokes a token of the authenticated user, it stops working right away
+func RevokePersonalAccessToken(context *gin.Context, tokenID string) *interfaces.ServiceError {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var storedToken models.PersonalAccessToken
+
+	if err := config.DB.First(&storedToken, "id = ? AND user_id = ?", tokenID, principal.UserID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	if storedToken.RevokedAt != nil {
+		return nil
+	}
+
+	if err := config.DB.Model(&storedToken).Update("revoked_at", time.Now()).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return nil
+}
+
+// authenticatePersonalAccessToken returns the principal of a personal access token, its scopes are
+// limited to the permissions the roles of the user still grant
+func authenticatePersonalAccessToken(token string) (*interfaces.Principal, error) {
+
+	var storedToken models.PersonalAccessToken
+
+	err := config.DB.Preload("User.Roles.Permissions").First(&storedToken, "token_hash = ?", hashToken(token)).Error
+
+	if err != nil {
+		return nil, errInvalidPersonalAccessToken
+	}
+
+	now := time.Now()
+
+	if storedToken.RevokedAt != nil || now.After(storedToken.ExpiresAt) || storedToken.User.DisabledAt != nil {
+		return nil, errInvalidPersonalAccessToken
+	}
+
+	if storedToken.LastUsedAt == nil || now.Sub(*storedToken.LastUsedAt) > personalAccessTokenUsageInterval {
+		config.DB.Model(&storedToken).UpdateColumn("last_used_at", now)
+	}
+
+	user := storedToken.User
+	permissions := rolePermissionNames(user.Roles)
+	scopes := []string{}
+
+	for _, scope := range strings.Fields(storedToken.Scopes) {
+		if containsString(permissions, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &interfaces.Principal{
+		UserID:              user.ID,
+		Email:               user.Email,
+		EmailVerified:       user.EmailVerifiedAt != nil,
+		Roles:               roleNames(user.Roles),
+		Scopes:              scopes,
+		TokenID:             storedToken.ID.String(),
+		TokenExpiresAt:      storedToken.ExpiresAt,
+		PersonalAccessToken: true,
+	}, nil
+}
+
+func personalAccessTokenDto(storedToken *models.PersonalAccessToken) *dtos.PersonalAccessTokenDto {
+	return &dtos.PersonalAccessTokenDto{
+		ID:         storedToken.ID.String(),
+		Name:       storedToken.Name,
+		Scopes:     strings.Fields(storedToken.Scopes),
+		CreatedAt:  storedToken.CreatedAt,
+		ExpiresAt:  storedToken.ExpiresAt,
+		LastUsedAt: storedToken.LastUsedAt,
+		RevokedAt:  storedToken.RevokedAt,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
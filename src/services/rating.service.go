@@ -0,0 +1,119 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockMovie takes a row lock on a movie for the rest of the transaction
+func lockMovie(tx *gorm.DB, movieID uuid.UUID) error {
+	var movie models.Movie
+
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&movie, "id = ?", movieID).Error
+}
+
+// refreshMovieRating recalculates AVGRating and NrOfRatings for a movie from its reviews.
+// It must be called inside the transaction that wrote the review, the movie row is locked
+// so concurrent review writes for the same movie are serialized instead of losing updates.
+func refreshMovieRating(tx *gorm.DB, movieID uuid.UUID) (*models.Movie, error) {
+	var movie models.Movie
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&movie, "id = ?", movieID).Error; err != nil {
+		return nil, err
+	}
+
+	var aggregate struct {
+		AVGRating   float64
+		NrOfRatings int
+	}
+
+	err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS avg_rating, COUNT(*) AS nr_of_ratings").
+		Where("movie_id = ?", movieID).
+		Scan(&aggregate).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&movie).Updates(map[string]interface{}{
+		"avg_rating":    aggregate.AVGRating,
+		"nr_of_ratings": aggregate.NrOfRatings,
+	}).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+func RecomputeMovieRating(movieID string) (*models.Movie, *interfaces.ServiceError) {
+	id, err := uuid.Parse(movieID)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	var movie *models.Movie
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		movie, err = refreshMovieRating(tx, id)
+		return err
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return movie, nil
+}
+
+// RecomputeAllMovieRatings repairs rating drift for every movie, one transaction per movie
+// so a long run does not hold locks on the whole catalogue.
+func RecomputeAllMovieRatings() (int, error) {
+	var movieIDs []uuid.UUID
+
+	if err := config.DB.Model(&models.Movie{}).Pluck("id", &movieIDs).Error; err != nil {
+		return 0, err
+	}
+
+	recomputed := 0
+
+	for _, movieID := range movieIDs {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			_, err := refreshMovieRating(tx, movieID)
+			return err
+		})
+
+		// the movie may have been deleted since the ids were read
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+
+		if err != nil {
+			return recomputed, err
+		}
+
+		recomputed++
+	}
+
+	return recomputed, nil
+}
@@ -1,24 +1,28 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/jaimy-monsuur/movie-api/src/config"
 	"github.com/jaimy-monsuur/movie-api/src/dtos"
 	"github.com/jaimy-monsuur/movie-api/src/interfaces"
 	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
 )
 
-func CreateReview(context *gin.Context, review dtos.CreateReviewDto) (*models.Review, *interfaces.ServiceError) {
-	//get user
-	user, err := GetUserByID(review.UserID)
+var errDuplicateReview = errors.New("you already reviewed this movie, update your existing review instead")
+
+func CreateReview(context *gin.Context, review dtos.CreateReviewV2Dto) (*models.Review, *interfaces.ServiceError) {
+	//the author is the authenticated user
+	principal, err := GetPrincipal(context)
 
 	if err != nil {
-		userNotFoundError := &interfaces.ServiceError{
+		userUnauthorizedError := &interfaces.ServiceError{
 			Error:      err,
-			StatusCode: 404,
+			StatusCode: 401,
 		}
-		return nil, userNotFoundError
+		return nil, userUnauthorizedError
 	}
 
 	//get movie
@@ -33,34 +37,110 @@ func CreateReview(context *gin.Context, review dtos.CreateReviewDto) (*models.Re
 	}
 
 	newReview := models.Review{
-		UserID:  uuid.MustParse(user.ID),
+		UserID:  principal.UserID,
 		MovieID: movie.ID,
 		Content: review.Review,
 		Rating:  float64(review.Rating),
 	}
 
-	//check if user from token is the same as the user in the request body
-	err = CheckUser(context, user.ID)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// the movie row lock serializes review writes, so no other review of this user can appear before the insert
+		if err := lockMovie(tx, newReview.MovieID); err != nil {
+			return err
+		}
+
+		var reviewExists models.Review
+
+		if err := tx.First(&reviewExists, "user_id = ? AND movie_id = ?", newReview.UserID, newReview.MovieID).Error; err == nil {
+			return errDuplicateReview
+		}
+
+		if err := tx.Create(&newReview).Error; err != nil {
+			return err
+		}
+
+		_, err := refreshMovieRating(tx, newReview.MovieID)
+		return err
+	})
+
+	if err == errDuplicateReview || isUniqueViolation(err) {
+		return nil, &interfaces.ServiceError{
+			Error:      errDuplicateReview,
+			StatusCode: 409,
+		}
+	}
+
+	if err != nil {
+		reviewCreateError := &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+		return nil, reviewCreateError
+	}
+
+	return &newReview, nil
+}
+
+// UpsertMyReview creates the review of the calling user for a movie or replaces it when it already exists,
+// the returned bool reports whether the review was created
+func UpsertMyReview(context *gin.Context, movieID string, review dtos.UpsertReviewDto) (*models.Review, bool, *interfaces.ServiceError) {
+	principal, err := GetPrincipal(context)
 
 	if err != nil {
-		userUnauthorizedError := &interfaces.ServiceError{
+		return nil, false, &interfaces.ServiceError{
 			Error:      err,
 			StatusCode: 401,
 		}
-		return nil, userUnauthorizedError
 	}
 
-	result := config.DB.Create(&newReview)
+	movie, err := GetMovieById(movieID)
 
-	if result.Error != nil {
-		reviewCreateError := &interfaces.ServiceError{
-			Error:      result.Error,
+	if err != nil {
+		return nil, false, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	var myReview models.Review
+	created := false
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockMovie(tx, movie.ID); err != nil {
+			return err
+		}
+
+		err := tx.First(&myReview, "user_id = ? AND movie_id = ?", principal.UserID, movie.ID).Error
+
+		if err == gorm.ErrRecordNotFound {
+			created = true
+			myReview = models.Review{
+				UserID:  principal.UserID,
+				MovieID: movie.ID,
+			}
+		} else if err != nil {
+			return err
+		}
+
+		myReview.Content = review.Review
+		myReview.Rating = float64(review.Rating)
+
+		if err := tx.Save(&myReview).Error; err != nil {
+			return err
+		}
+
+		_, err = refreshMovieRating(tx, movie.ID)
+		return err
+	})
+
+	if err != nil {
+		return nil, false, &interfaces.ServiceError{
+			Error:      err,
 			StatusCode: 400,
 		}
-		return nil, reviewCreateError
 	}
 
-	return &newReview, nil
+	return &myReview, created, nil
 }
 
 func GetReviewsByMovieId(ID string) ([]*models.Review, error) {
@@ -89,10 +169,10 @@ func UpdateReview(context *gin.Context, ID string, review dtos.UpdateReviewDto)
 		return nil, reviewNotFoundError
 	}
 
-	//check if user from token is the same as the user in the request body
+	//check if user from token is the same as the user in the request body, moderators may update any review
 	err = CheckUser(context, reviewToUpdate.UserID.String())
 
-	if err != nil {
+	if err != nil && !principalHasPermission(context, models.PermissionReviewsModerate) {
 		userUnauthorizedError := &interfaces.ServiceError{
 			Error:      err,
 			StatusCode: 401,
@@ -103,11 +183,18 @@ func UpdateReview(context *gin.Context, ID string, review dtos.UpdateReviewDto)
 	reviewToUpdate.Content = review.Review
 	reviewToUpdate.Rating = float64(review.Rating)
 
-	result := config.DB.Save(&reviewToUpdate)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&reviewToUpdate).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
+		_, err := refreshMovieRating(tx, reviewToUpdate.MovieID)
+		return err
+	})
+
+	if err != nil {
 		reviewUpdateError := &interfaces.ServiceError{
-			Error:      result.Error,
+			Error:      err,
 			StatusCode: 400,
 		}
 		return nil, reviewUpdateError
@@ -129,11 +216,18 @@ func DeleteReview(ID string) *interfaces.ServiceError {
 		return reviewNotFoundError
 	}
 
-	result := config.DB.Delete(&reviewToDelete)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&reviewToDelete).Error; err != nil {
+			return err
+		}
 
-	if result.Error != nil {
+		_, err := refreshMovieRating(tx, reviewToDelete.MovieID)
+		return err
+	})
+
+	if err != nil {
 		reviewDeleteError := &interfaces.ServiceError{
-			Error:      result.Error,
+			Error:      err,
 			StatusCode: 400,
 		}
 		return reviewDeleteError
@@ -0,0 +1,508 @@
+package services
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// new users get this role, it allows reading the catalog and writing reviews
+const defaultRole = models.RoleReviewer
+
+var (
+	errUnknownRole       = errors.New("unknown role")
+	errUnknownPermission = errors.New("unknown permission")
+	errBuiltInRole       = errors.New("built-in roles cannot be changed")
+	errLastAdmin         = errors.New("the last admin cannot lose the admin role")
+)
+
+var permissionDefinitions = []dtos.PermissionDto{
+	{Name: models.PermissionMoviesRead, Description: "Read movies, people, genres and keywords"},
+	{Name: models.PermissionMoviesWrite, Description: "Create and update movies, credits, genres and keywords"},
+	{Name: models.PermissionMoviesDelete, Description: "Delete movies, credits, genres and keywords"},
+	{Name: models.PermissionReviewsRead, Description: "Read reviews"},
+	{Name: models.PermissionReviewsWrite, Description: "Write and update own reviews"},
+	{Name: models.PermissionReviewsModerate, Description: "Update and delete reviews of other users"},
+	{Name: models.PermissionUsersRead, Description: "Read and search all users, including their email addresses and login activity"},
+	{Name: models.PermissionUsersWrite, Description: "Manage other users, e.g. unlock them"},
+	{Name: models.PermissionUsersDelete, Description: "Delete users"},
+	{Name: models.PermissionRolesManage, Description: "Manage roles and assign them to users"},
+	{Name: models.PermissionAuditRead, Description: "Read the audit log and login lockouts"},
+}
+
+var builtInRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	// users:read exposes the email addresses and login activity of every user, only admins get it
+	{
+		name:        models.RoleViewer,
+		description: "Reads the catalog and reviews",
+		permissions: []string{models.PermissionMoviesRead, models.PermissionReviewsRead},
+	},
+	{
+		name:        models.RoleReviewer,
+		description: "Viewer that writes reviews",
+		permissions: []string{models.PermissionMoviesRead, models.PermissionReviewsRead, models.PermissionReviewsWrite},
+	},
+	{
+		name:        models.RoleModerator,
+		description: "Reviewer that moderates the reviews of others",
+		permissions: []string{models.PermissionMoviesRead, models.PermissionReviewsRead, models.PermissionReviewsWrite, models.PermissionReviewsModerate},
+	},
+	{
+		name:        models.RoleCatalogEditor,
+		description: "Manages movies, credits, genres and keywords",
+		permissions: []string{models.PermissionMoviesRead, models.PermissionReviewsRead, models.PermissionMoviesWrite, models.PermissionMoviesDelete},
+	},
+	{
+		name:        models.RoleAdmin,
+		description: "Has every permission",
+		permissions: permissionNames(),
+	},
+}
+
+// SeedRoles creates the permissions and built-in roles, resetting built-in roles to their definition
+func SeedRoles() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, definition := range permissionDefinitions {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			}).Create(&models.Permission{Name: definition.Name, Description: definition.Description}).Error
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, definition := range builtInRoles {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description", "built_in"}),
+			}).Create(&models.Role{Name: definition.name, Description: definition.description, BuiltIn: true}).Error
+
+			if err != nil {
+				return err
+			}
+
+			var role models.Role
+
+			if err := tx.First(&role, "name = ?", definition.name).Error; err != nil {
+				return err
+			}
+
+			if err := replaceRolePermissions(tx, &role, definition.permissions); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func GetAllPermissions() ([]*dtos.PermissionDto, error) {
+
+	var storedPermissions []*models.Permission
+
+	if err := config.DB.Order("name").Find(&storedPermissions).Error; err != nil {
+		return nil, err
+	}
+
+	returnPermissions := []*dtos.PermissionDto{}
+
+	for _, permission := range storedPermissions {
+		returnPermissions = append(returnPermissions, &dtos.PermissionDto{
+			Name:        permission.Name,
+			Description: permission.Description,
+		})
+	}
+
+	return returnPermissions, nil
+}
+
+func GetAllRoles() ([]*dtos.RoleDto, error) {
+
+	var roles []*models.Role
+
+	if err := config.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	returnRoles := []*dtos.RoleDto{}
+
+	for _, role := range roles {
+		returnRoles = append(returnRoles, roleDto(role))
+	}
+
+	return returnRoles, nil
+}
+
+func CreateRole(createRoleDto *dtos.CreateRoleDto) (*dtos.RoleDto, *interfaces.ServiceError) {
+
+	role := models.Role{
+		Name:        createRoleDto.Name,
+		Description: createRoleDto.Description,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+
+		return replaceRolePermissions(tx, &role, createRoleDto.Permissions)
+	})
+
+	if isUniqueViolation(err) {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("role with name: " + createRoleDto.Name + " already exists"),
+			StatusCode: 409,
+		}
+	}
+
+	if err != nil {
+		return nil, roleServiceError(err)
+	}
+
+	return roleDto(&role), nil
+}
+
+// UpdateRole replaces the description and permissions of a custom role, tokens of its users are invalidated
+func UpdateRole(roleID string, updateRoleDto *dtos.UpdateRoleDto) (*dtos.RoleDto, *interfaces.ServiceError) {
+
+	var role models.Role
+	var userIDs []uuid.UUID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&role, "id = ?", roleID).Error; err != nil {
+			return err
+		}
+
+		if role.BuiltIn {
+			return errBuiltInRole
+		}
+
+		if err := tx.Model(&role).Update("description", updateRoleDto.Description).Error; err != nil {
+			return err
+		}
+
+		if err := replaceRolePermissions(tx, &role, updateRoleDto.Permissions); err != nil {
+			return err
+		}
+
+		var err error
+		userIDs, err = invalidateRoleTokens(tx, role.ID)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, roleServiceError(err)
+	}
+
+	forgetTokenVersions(userIDs...)
+
+	return roleDto(&role), nil
+}
+
+// DeleteRole deletes a custom role, its users lose its permissions right away
+func DeleteRole(roleID string) *interfaces.ServiceError {
+
+	var userIDs []uuid.UUID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+
+		if err := tx.First(&role, "id = ?", roleID).Error; err != nil {
+			return err
+		}
+
+		if role.BuiltIn {
+			return errBuiltInRole
+		}
+
+		var err error
+
+		if userIDs, err = invalidateRoleTokens(tx, role.ID); err != nil {
+			return err
+		}
+
+		return tx.Delete(&role).Error
+	})
+
+	if err != nil {
+		return roleServiceError(err)
+	}
+
+	forgetTokenVersions(userIDs...)
+
+	return nil
+}
+
+// SetUserRoles replaces the roles of a user, tokens issued with the old roles stop working
+func SetUserRoles(userID string, updateUserRolesDto *dtos.UpdateUserRolesDto) (*dtos.UserDto, *interfaces.ServiceError) {
+
+	var user models.User
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Roles").First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		roles, err := findRoles(tx, updateUserRolesDto.Roles)
+
+		if err != nil {
+			return err
+		}
+
+		if containsString(roleNames(user.Roles), models.RoleAdmin) && !containsString(roleNames(roles), models.RoleAdmin) {
+			// lock the admin role, so two admins cannot demote each other at the same time
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Role{}, "name = ?", models.RoleAdmin).Error; err != nil {
+				return err
+			}
+
+			var otherAdmins int64
+
+			err := tx.Table("user_roles").
+				Joins("JOIN roles ON roles.id = user_roles.role_id").
+				Where("roles.name = ? AND user_roles.user_id <> ?", models.RoleAdmin, user.ID).
+				Count(&otherAdmins).Error
+
+			if err != nil {
+				return err
+			}
+
+			if otherAdmins == 0 {
+				return errLastAdmin
+			}
+		}
+
+		if err := assignRoles(tx, &user, roles); err != nil {
+			return err
+		}
+
+		return InvalidateUserTokens(tx, user.ID)
+	})
+
+	if err != nil {
+		return nil, roleServiceError(err)
+	}
+
+	forgetTokenVersions(user.ID)
+
+	return userDtoByID(userID)
+}
+
+// loadUserRoles returns the roles of a user with their permissions
+func loadUserRoles(db *gorm.DB, userID uuid.UUID) ([]models.Role, error) {
+
+	var roles []models.Role
+
+	err := db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Find(&roles).Error
+
+	return roles, err
+}
+
+// userHasRole reports whether the user has the role, errors count as having it so checks fail closed
+func userHasRole(userID uuid.UUID, roleName string) bool {
+
+	var count int64
+
+	err := config.DB.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("roles.name = ? AND user_roles.user_id = ?", roleName, userID).
+		Count(&count).Error
+
+	return err != nil || count > 0
+}
+
+// principalHasPermission reports whether the authenticated principal has the permission
+func principalHasPermission(context *gin.Context, permission string) bool {
+
+	principal, err := GetPrincipal(context)
+
+	return err == nil && principal.HasScope(permission)
+}
+
+func assignRoles(tx *gorm.DB, user *models.User, roles []models.Role) error {
+	return tx.Model(user).Omit("Roles.*").Association("Roles").Replace(roles)
+}
+
+// assignDefaultRole gives a new user the default role
+func assignDefaultRole(tx *gorm.DB, user *models.User) error {
+
+	roles, err := findRoles(tx, []string{defaultRole})
+
+	if err != nil {
+		return err
+	}
+
+	return assignRoles(tx, user, roles)
+}
+
+// findRoles returns the roles with the names, failing when one of them does not exist
+func findRoles(tx *gorm.DB, names []string) ([]models.Role, error) {
+
+	var roles []models.Role
+
+	if err := tx.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if !containsString(roleNames(roles), name) {
+			return nil, errUnknownRole
+		}
+	}
+
+	return roles, nil
+}
+
+func replaceRolePermissions(tx *gorm.DB, role *models.Role, names []string) error {
+
+	var rolePermissions []models.Permission
+
+	if len(names) > 0 {
+		if err := tx.Where("name IN ?", names).Find(&rolePermissions).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		if !isKnownPermission(name) {
+			return errUnknownPermission
+		}
+	}
+
+	role.Permissions = rolePermissions
+
+	return tx.Model(role).Omit("Permissions.*").Association("Permissions").Replace(rolePermissions)
+}
+
+// invalidateRoleTokens invalidates the tokens of every user with the role, as their permissions changed.
+// It returns the users, their cached token versions are forgotten once the transaction commits
+func invalidateRoleTokens(tx *gorm.DB, roleID uuid.UUID) ([]uuid.UUID, error) {
+
+	var userIDs []uuid.UUID
+
+	if err := tx.Table("user_roles").Where("role_id = ?", roleID).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	err := tx.Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+
+	return userIDs, err
+}
+
+func roleNames(roles []models.Role) []string {
+
+	names := []string{}
+
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
+
+// rolePermissionNames returns the distinct permissions of the roles, sorted
+func rolePermissionNames(roles []models.Role) []string {
+
+	names := []string{}
+
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if !containsString(names, permission.Name) {
+				names = append(names, permission.Name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func permissionNames() []string {
+
+	names := []string{}
+
+	for _, definition := range permissionDefinitions {
+		names = append(names, definition.Name)
+	}
+
+	return names
+}
+
+func isKnownPermission(name string) bool {
+	return containsString(permissionNames(), name)
+}
+
+func roleDto(role *models.Role) *dtos.RoleDto {
+
+	permissions := []string{}
+
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+
+	sort.Strings(permissions)
+
+	return &dtos.RoleDto{
+		ID:          role.ID.String(),
+		Name:        role.Name,
+		Description: role.Description,
+		BuiltIn:     role.BuiltIn,
+		Permissions: permissions,
+	}
+}
+
+func roleServiceError(err error) *interfaces.ServiceError {
+	switch err {
+	case nil:
+		return nil
+	case gorm.ErrRecordNotFound:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	case errUnknownRole, errUnknownPermission:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	case errBuiltInRole:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 403,
+		}
+	case errLastAdmin:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 409,
+		}
+	default:
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+}
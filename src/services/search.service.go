@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+)
+
+const defaultSearchPageLimit = 20
+
+// searchTermPattern matches "quoted phrases" and single words of a search query
+var searchTermPattern = regexp.MustCompile(`"[^"]*"|\S+`)
+
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// escapedPlot is the plot with HTML special characters escaped, so the <mark> tags are the only markup in a snippet
+const escapedPlot = `replace(replace(replace(replace(replace(plot, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+func SearchMovies(query dtos.MovieSearchQueryDto) ([]*dtos.MovieSearchResultDto, *dtos.PaginationDto, *interfaces.ServiceError) {
+	if query.Limit == 0 {
+		query.Limit = defaultSearchPageLimit
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	tsQuery := buildTsQuery(query.Q)
+
+	if tsQuery == "" {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      errors.New("search query does not contain any searchable words"),
+			StatusCode: 400,
+		}
+	}
+
+	var total int64
+
+	err := config.DB.Raw("SELECT COUNT(*) FROM movies WHERE search_vector @@ to_tsquery('english', ?)", tsQuery).
+		Scan(&total).Error
+
+	if err != nil {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	var results []*dtos.MovieSearchResultDto
+
+	err = config.DB.Raw(`SELECT id, title, language, length, year, director, actors, avg_rating, nr_of_ratings,
+			ts_rank(search_vector, search_query) AS rank,
+			ts_headline('english', `+escapedPlot+`, search_query, ?) AS snippet
+		FROM movies, to_tsquery('english', ?) search_query
+		WHERE search_vector @@ search_query
+		ORDER BY rank DESC, title
+		LIMIT ? OFFSET ?`, searchHeadlineOptions, tsQuery, query.Limit, (query.Page-1)*query.Limit).
+		Scan(&results).Error
+
+	if err != nil {
+		return nil, nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	pagination := &dtos.PaginationDto{
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+
+	return results, pagination, nil
+}
+
+// buildTsQuery turns a user query into a to_tsquery expression, all terms must match.
+// "quoted phrases" become followed-by (<->) chains and terms ending in * become prefix matches,
+// every other tsquery operator is stripped so user input can never cause a syntax error
+func buildTsQuery(q string) string {
+	var terms []string
+
+	for _, term := range searchTermPattern.FindAllString(q, -1) {
+
+		if strings.HasPrefix(term, "\"") {
+			phrase := searchWords(term)
+
+			if len(phrase) > 0 {
+				terms = append(terms, "("+strings.Join(phrase, " <-> ")+")")
+			}
+			continue
+		}
+
+		words := searchWords(term)
+
+		if len(words) == 0 {
+			continue
+		}
+
+		if strings.HasSuffix(term, "*") {
+			words[len(words)-1] += ":*"
+		}
+
+		terms = append(terms, strings.Join(words, " & "))
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// searchWords splits text into plain words, dropping every character that is not a letter or digit
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"os"
+)
+
+// secrets like private signing keys and TOTP secrets are encrypted at rest with AES-GCM,
+// using a key derived from JWT_SECRET
+func secretCipher() (cipher.AEAD, error) {
+	secret := os.Getenv("JWT_SECRET")
+
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET is required to encrypt secrets")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+
+	block, err := aes.NewCipher(key[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func encryptSecret(plaintext []byte) ([]byte, error) {
+	aead, err := secretCipher()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decryptSecret(encrypted []byte) ([]byte, error) {
+	aead, err := secretCipher()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("encrypted secret is too short")
+	}
+
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// last_seen_at is written at most once per interval, not on every request
+	sessionActivityInterval = time.Minute
+
+	maxUserAgentLength = 512
+)
+
+var errSessionRevoked = errors.New("session has been revoked")
+
+type cachedSession struct {
+	active    bool
+	expiresAt time.Time
+}
+
+// sessions are cached like token versions, a revoked session is picked up by other instances within tokenVersionCacheTTL
+var sessionCache sync.Map
+
+// GetSessions returns the logins of the authenticated user that can still be refreshed, most recently used first
+func GetSessions(context *gin.Context) ([]*dtos.SessionDto, *interfaces.ServiceError) {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var sessions []*models.Session
+
+	err = config.DB.Where("user_id = ? AND revoked_at IS NULL", principal.UserID).
+		Where(`EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = sessions.id
+			AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.expires_at > ?)`, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	returnSessions := []*dtos.SessionDto{}
+
+	for _, session := range sessions {
+		returnSessions = append(returnSessions, &dtos.SessionDto{
+			ID:         session.ID.String(),
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == principal.SessionID,
+		})
+	}
+
+	return returnSessions, nil
+}
+
+// RevokeSession ends a login of the authenticated user, its tokens stop working right away
+func RevokeSession(context *gin.Context, sessionID string) *interfaces.ServiceError {
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	var session models.Session
+
+	if err := config.DB.First(&session, "id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, principal.UserID).Error; err != nil {
+		return &interfaces.ServiceError{
+			Error:      errors.New("session not found"),
+			StatusCode: 404,
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSession(tx, session.ID)
+	})
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:     models.AuditSessionRevoked,
+		UserID:    &principal.UserID,
+		Email:     normalizeEmail(principal.Email),
+		IPAddress: context.ClientIP(),
+		Detail:    session.UserAgent + " from " + session.IPAddress,
+	})
+
+	return nil
+}
+
+// startSession records a login from the device of the request and returns the session id,
+// which is the family id of the refresh tokens of the login
+func startSession(tx *gorm.DB, userID uuid.UUID, context *gin.Context) (uuid.UUID, error) {
+
+	// sessions that were not refreshed for longer than a refresh token lives cannot come back
+	err := tx.Where("user_id = ? AND last_seen_at < ?", userID, time.Now().Add(-refreshTokenTTL)).
+		Delete(&models.Session{}).Error
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	session := models.Session{
+		UserID:     userID,
+		UserAgent:  userAgent(context),
+		IPAddress:  context.ClientIP(),
+		LastSeenAt: time.Now(),
+	}
+
+	if err := tx.Omit("User").Create(&session).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	return session.ID, nil
+}
+
+// touchSession records a refresh of the session from the device of the request. Refresh token families
+// from before sessions existed get their session now
+func touchSession(tx *gorm.DB, sessionID uuid.UUID, userID uuid.UUID, context *gin.Context) error {
+
+	session := models.Session{
+		Base:       models.Base{ID: sessionID},
+		UserID:     userID,
+		UserAgent:  userAgent(context),
+		IPAddress:  context.ClientIP(),
+		LastSeenAt: time.Now(),
+	}
+
+	result := tx.Model(&models.Session{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"user_agent":   session.UserAgent,
+		"ip_address":   session.IPAddress,
+		"last_seen_at": session.LastSeenAt,
+	})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return tx.Omit("User").Create(&session).Error
+	}
+
+	var stored models.Session
+
+	if err := tx.Select("revoked_at").First(&stored, "id = ?", sessionID).Error; err != nil {
+		return err
+	}
+
+	if stored.RevokedAt != nil {
+		return errSessionRevoked
+	}
+
+	return nil
+}
+
+// revokeSession ends a login, its refresh tokens are revoked and its access tokens rejected
+func revokeSession(tx *gorm.DB, sessionID uuid.UUID) error {
+
+	err := tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+
+	if err != nil {
+		return err
+	}
+
+	sessionCache.Delete(sessionID.String())
+
+	return revokeRefreshTokens(tx.Where("family_id = ?", sessionID))
+}
+
+func isSessionActive(sessionID string) (bool, error) {
+
+	if cached, ok := sessionCache.Load(sessionID); ok {
+		if entry := cached.(cachedSession); time.Now().Before(entry.expiresAt) {
+			return entry.active, nil
+		}
+	}
+
+	var session models.Session
+
+	err := config.DB.First(&session, "id = ?", sessionID).Error
+
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	active := err == nil && session.RevokedAt == nil
+
+	if active && time.Since(session.LastSeenAt) > sessionActivityInterval {
+		config.DB.Model(&session).UpdateColumn("last_seen_at", time.Now())
+	}
+
+	sessionCache.Store(sessionID, cachedSession{
+		active:    active,
+		expiresAt: time.Now().Add(tokenVersionCacheTTL),
+	})
+
+	return active, nil
+}
+
+func userAgent(context *gin.Context) string {
+
+	userAgent := context.Request.UserAgent()
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return userAgent
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// a new signing key takes over every signingKeyRotationInterval
+	signingKeyRotationInterval = 30 * 24 * time.Hour
+
+	// the next key is published in the JWKS this long before it starts signing,
+	// so verifiers that cache the JWKS already know it when the first token arrives
+	signingKeyPrepublishPeriod = 24 * time.Hour
+
+	// a retired key keeps verifying the tokens it signed for this long
+	signingKeyVerificationPeriod = 24 * time.Hour
+
+	keyRingReloadInterval = time.Minute
+
+	// serializes key creation between instances
+	signingKeyAdvisoryLock = 7231001
+
+	defaultSigningAlgorithm = "RS256"
+	rsaKeyBits              = 2048
+)
+
+type signingKey struct {
+	id          string
+	method      jwt.SigningMethod
+	privateKey  crypto.Signer
+	activatesAt time.Time
+	retiresAt   time.Time
+	expiresAt   time.Time
+}
+
+// keyRing holds the signing keys that have not expired, newest first
+var keyRing = struct {
+	sync.Mutex
+	keys     []*signingKey
+	lastLoad time.Time
+}{}
+
+// currentSigningKey returns the key tokens are signed with right now
+func currentSigningKey() (*signingKey, error) {
+	keys, err := loadedSigningKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, key := range keys {
+		if !now.Before(key.activatesAt) && now.Before(key.retiresAt) {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("no active signing key")
+}
+
+// verificationKey returns the key with the given kid when it may still be used to verify tokens
+func verificationKey(kid string) (*signingKey, error) {
+	keys, err := loadedSigningKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, key := range keys {
+		if key.id == kid && now.Before(key.expiresAt) {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key: %v", kid)
+}
+
+// GetJwks returns the public keys of every signing key that has not expired, including the next key
+func GetJwks() (*dtos.JwksDto, error) {
+	keys, err := loadedSigningKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &dtos.JwksDto{Keys: []*dtos.JwkDto{}}
+	now := time.Now()
+
+	for _, key := range keys {
+		if !now.Before(key.expiresAt) {
+			continue
+		}
+
+		jwk := &dtos.JwkDto{
+			Kid: key.id,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+
+		switch publicKey := key.privateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+// loadedSigningKeys reloads the key ring from the database every keyRingReloadInterval,
+// creating the current or next key first when the rotation schedule asks for it
+func loadedSigningKeys() ([]*signingKey, error) {
+	keyRing.Lock()
+	defer keyRing.Unlock()
+
+	if time.Since(keyRing.lastLoad) < keyRingReloadInterval {
+		return keyRing.keys, nil
+	}
+
+	keys, err := rotateAndLoadSigningKeys()
+
+	if err != nil {
+		// keep using the keys that are already loaded when the database is unavailable
+		if len(keyRing.keys) > 0 {
+			return keyRing.keys, nil
+		}
+		return nil, err
+	}
+
+	keyRing.keys = keys
+	keyRing.lastLoad = time.Now()
+
+	return keys, nil
+}
+
+func rotateAndLoadSigningKeys() ([]*signingKey, error) {
+	var storedKeys []*models.SigningKey
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyAdvisoryLock).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var latest models.SigningKey
+
+		err := tx.Order("activates_at DESC").First(&latest).Error
+
+		switch {
+		case err == gorm.ErrRecordNotFound || (err == nil && !now.Before(latest.RetiresAt)):
+			if err := createSigningKey(tx, now); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case latest.RetiresAt.Sub(now) < signingKeyPrepublishPeriod:
+			if err := createSigningKey(tx, latest.RetiresAt); err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("expires_at > ?", now).Order("activates_at DESC").Find(&storedKeys).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*signingKey{}
+
+	for _, storedKey := range storedKeys {
+		key, err := decodeSigningKey(storedKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// createSigningKey generates a key pair with the algorithm of JWT_SIGNING_ALG (RS256 or EdDSA)
+func createSigningKey(tx *gorm.DB, activatesAt time.Time) error {
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	if algorithm == "" {
+		algorithm = defaultSigningAlgorithm
+	}
+
+	var privateKey crypto.Signer
+	var err error
+
+	switch algorithm {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("unsupported JWT_SIGNING_ALG: %v", algorithm)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	if err != nil {
+		return err
+	}
+
+	publicDer, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+
+	if err != nil {
+		return err
+	}
+
+	encryptedPrivateKey, err := encryptSecret(privateDer)
+
+	if err != nil {
+		return err
+	}
+
+	retiresAt := activatesAt.Add(signingKeyRotationInterval)
+
+	return tx.Create(&models.SigningKey{
+		Algorithm:   algorithm,
+		PrivateKey:  encryptedPrivateKey,
+		PublicKey:   publicDer,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(signingKeyVerificationPeriod),
+	}).Error
+}
+
+func decodeSigningKey(storedKey *models.SigningKey) (*signingKey, error) {
+	privateDer, err := decryptSecret(storedKey.PrivateKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsedKey, err := x509.ParsePKCS8PrivateKey(privateDer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := parsedKey.(crypto.Signer)
+
+	if !ok {
+		return nil, fmt.Errorf("signing key %v is not a signer", storedKey.ID)
+	}
+
+	method := jwt.GetSigningMethod(storedKey.Algorithm)
+
+	if method == nil {
+		return nil, fmt.Errorf("signing key %v has unsupported algorithm %v", storedKey.ID, storedKey.Algorithm)
+	}
+
+	return &signingKey{
+		id:          storedKey.ID.String(),
+		method:      method,
+		privateKey:  privateKey,
+		activatesAt: storedKey.ActivatesAt,
+		retiresAt:   storedKey.RetiresAt,
+		expiresAt:   storedKey.ExpiresAt,
+	}, nil
+}
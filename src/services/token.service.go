@@ -0,0 +1,323 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/dtos"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// revoked access tokens of other instances are picked up within this interval
+	denylistSyncInterval = 10 * time.Second
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// denylist is an in memory copy of the revoked_tokens table, so checking a token does not need the database
+var denylist = struct {
+	sync.Mutex
+	revoked  map[string]time.Time
+	lastSync time.Time
+}{revoked: map[string]time.Time{}}
+
+// IssueTokens logs a user in on the device of the request, returning an access token and the refresh token
+// of a new session
+func IssueTokens(context *gin.Context, user *models.User) (*dtos.TokenDto, error) {
+	var tokens *dtos.TokenDto
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		sessionID, err := startSession(tx, user.ID, context)
+
+		if err != nil {
+			return err
+		}
+
+		tokens, _, err = issueTokens(tx, user, sessionID)
+
+		return err
+	})
+
+	return tokens, err
+}
+
+// RefreshTokens exchanges a refresh token for new tokens. A refresh token can only be used once,
+// using it again means it was stolen, so the whole session is revoked and the user has to log in again
+func RefreshTokens(context *gin.Context, refreshToken string) (*dtos.TokenDto, *interfaces.ServiceError) {
+	var tokens *dtos.TokenDto
+	reuseDetected := false
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var storedToken models.RefreshToken
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&storedToken, "token_hash = ?", hashToken(refreshToken)).Error
+
+		if err != nil {
+			return errInvalidRefreshToken
+		}
+
+		// only a token that was already exchanged is reuse, tokens revoked by a logout are just invalid
+		if storedToken.RevokedAt != nil && storedToken.ReplacedByID != nil {
+			reuseDetected = true
+			return nil
+		}
+
+		if storedToken.RevokedAt != nil {
+			return errInvalidRefreshToken
+		}
+
+		if time.Now().After(storedToken.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		var user models.User
+
+		if err := tx.First(&user, "id = ?", storedToken.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		if err := touchSession(tx, storedToken.FamilyID, user.ID, context); err != nil {
+			return err
+		}
+
+		var replacementID uuid.UUID
+
+		tokens, replacementID, err = issueTokens(tx, &user, storedToken.FamilyID)
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&storedToken).Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"replaced_by_id": replacementID,
+		}).Error
+	})
+
+	// the session is revoked outside the lookup transaction, so it is not rolled back with it
+	if reuseDetected {
+		var storedToken models.RefreshToken
+
+		if err := config.DB.First(&storedToken, "token_hash = ?", hashToken(refreshToken)).Error; err == nil {
+			revokeSession(config.DB, storedToken.FamilyID)
+		}
+
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("refresh token reuse detected, all sessions of this login were revoked"),
+			StatusCode: 401,
+		}
+	}
+
+	if err == errInvalidRefreshToken || err == errUserDisabled || err == errSessionRevoked {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the access token and the session of the principal, and the session of the supplied refresh token
+func Logout(principal *interfaces.Principal, refreshToken string) error {
+
+	if err := RevokeAccessToken(principal.TokenID, principal.TokenExpiresAt); err != nil {
+		return err
+	}
+
+	if principal.SessionID != uuid.Nil {
+		if err := revokeSession(config.DB, principal.SessionID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	var storedToken models.RefreshToken
+
+	err := config.DB.First(&storedToken, "token_hash = ? AND user_id = ?", hashToken(refreshToken), principal.UserID).Error
+
+	if err != nil {
+		return nil
+	}
+
+	return revokeSession(config.DB, storedToken.FamilyID)
+}
+
+// LogoutAll revokes every session and refresh token of the user and invalidates all of its access tokens
+func LogoutAll(userID uuid.UUID) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+
+		if err != nil {
+			return err
+		}
+
+		if err := revokeRefreshTokens(tx.Where("user_id = ?", userID)); err != nil {
+			return err
+		}
+
+		return InvalidateUserTokens(tx, userID)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	forgetTokenVersions(userID)
+
+	return nil
+}
+
+// RevokeAccessToken puts the id of an access token on the denylist until the token expires
+func RevokeAccessToken(jti string, expiresAt time.Time) error {
+
+	err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}).Error
+
+	if err != nil {
+		return err
+	}
+
+	denylist.Lock()
+	denylist.revoked[jti] = expiresAt
+	denylist.Unlock()
+
+	return nil
+}
+
+func isTokenRevoked(jti string) (bool, error) {
+	denylist.Lock()
+	defer denylist.Unlock()
+
+	if time.Since(denylist.lastSync) > denylistSyncInterval {
+		if err := syncDenylist(); err != nil {
+			return false, err
+		}
+	}
+
+	_, revoked := denylist.revoked[jti]
+
+	return revoked, nil
+}
+
+// syncDenylist loads the tokens revoked since the last sync and forgets expired ones, the denylist must be locked
+func syncDenylist() error {
+	now := time.Now()
+
+	var revokedTokens []*models.RevokedToken
+
+	// overlap with the previous sync so tokens committed during it are not missed
+	err := config.DB.
+		Where("created_at >= ? AND expires_at > ?", denylist.lastSync.Add(-denylistSyncInterval), now).
+		Find(&revokedTokens).Error
+
+	if err != nil {
+		return err
+	}
+
+	for _, revokedToken := range revokedTokens {
+		denylist.revoked[revokedToken.JTI] = revokedToken.ExpiresAt
+	}
+
+	for jti, expiresAt := range denylist.revoked {
+		if now.After(expiresAt) {
+			delete(denylist.revoked, jti)
+		}
+	}
+
+	config.DB.Where("expires_at <= ?", now).Delete(&models.RevokedToken{})
+
+	denylist.lastSync = now
+
+	return nil
+}
+
+// issueTokens creates an access token and a refresh token of the given session, returning the id of the refresh token
+func issueTokens(tx *gorm.DB, user *models.User, sessionID uuid.UUID) (*dtos.TokenDto, uuid.UUID, error) {
+	if user.DisabledAt != nil {
+		return nil, uuid.Nil, errUserDisabled
+	}
+
+	accessToken, err := GenerateJwt(user, sessionID)
+
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	refreshToken, err := randomToken()
+
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	storedToken := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	if err := tx.Omit("User").Create(&storedToken).Error; err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return &dtos.TokenDto{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, storedToken.ID, nil
+}
+
+func revokeRefreshTokens(tokens *gorm.DB) error {
+	return tokens.Model(&models.RefreshToken{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+// randomToken returns 32 random bytes, url safe base64 encoded
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
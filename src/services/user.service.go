@@ -2,11 +2,14 @@ package services
 
 import (
 	"errors"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jaimy-monsuur/movie-api/src/config"
 	"github.com/jaimy-monsuur/movie-api/src/dtos"
 	"github.com/jaimy-monsuur/movie-api/src/interfaces"
 	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
 )
 
 func CreateUser(createUserDto *dtos.CreateUserDto) (*models.User, *interfaces.ServiceError) {
@@ -31,44 +34,113 @@ func CreateUser(createUserDto *dtos.CreateUserDto) (*models.User, *interfaces.Se
 		LastName:  createUserDto.LastName,
 	}
 
-	result := config.DB.Create(&newUser)
+	if serviceError := checkPasswordPolicy("password", createUserDto.Password, &newUser); serviceError != nil {
+		return nil, serviceError
+	}
 
-	if result.Error != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+
+		return assignDefaultRole(tx, &newUser)
+	})
+
+	if err != nil {
 
 		userCreateError := &interfaces.ServiceError{
-			Error:      result.Error,
+			Error:      err,
 			StatusCode: 400,
 		}
 		return nil, userCreateError
 	}
 
+	SendVerificationEmail(&newUser)
+
 	newUser.Password = ""
 
 	return &newUser, nil
 }
 
-func GetAllUsers() ([]*dtos.UserDto, error) {
+const defaultUserPageLimit = 50
+
+var (
+	errUserDisabled          = errors.New("account is disabled")
+	errDisableOwnAccount     = errors.New("you cannot disable your own account")
+	errPasswordResetRequired = errors.New("a password reset is required, use the link sent by email")
+)
+
+// GetAllUsers returns the users matching the query, ordered by email
+func GetAllUsers(query dtos.UserQueryDto) ([]*dtos.UserDto, *dtos.PaginationDto, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultUserPageLimit
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	var total int64
+
+	if err := filterUsers(config.DB.Model(&models.User{}), query).Count(&total).Error; err != nil {
+		return nil, nil, err
+	}
 
 	var allUsers []*models.User
 
-	err := config.DB.Select("id", "email", "first_name", "last_name", "last_login", "created_at", "updated_at").Find(&allUsers).Error
+	err := filterUsers(config.DB, query).
+		Select("id", "email", "first_name", "last_name", "last_login", "disabled_at", "created_at", "updated_at").
+		Preload("Roles").
+		Order("email").Order("id").
+		Limit(query.Limit).Offset((query.Page - 1) * query.Limit).
+		Find(&allUsers).Error
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var returnUsers []*dtos.UserDto
+	returnUsers := []*dtos.UserDto{}
 
 	for _, user := range allUsers {
-		var userDto dtos.UserDto
-		userDto.ID = user.ID.String()
-		userDto.Email = user.Email
-		userDto.FirstName = user.FirstName
-		userDto.LastName = user.LastName
-		returnUsers = append(returnUsers, &userDto)
+		returnUsers = append(returnUsers, userDto(user))
+	}
+
+	pagination := &dtos.PaginationDto{
+		Total:      total,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
 	}
 
-	return returnUsers, nil
+	return returnUsers, pagination, nil
+}
+
+func filterUsers(db *gorm.DB, query dtos.UserQueryDto) *gorm.DB {
+	if query.Email != "" {
+		db = db.Where("email ILIKE ?", "%"+query.Email+"%")
+	}
+	if query.Name != "" {
+		db = db.Where("(first_name || ' ' || last_name) ILIKE ?", "%"+query.Name+"%")
+	}
+	if query.Role != "" {
+		db = db.Where("id IN (?)", config.DB.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", query.Role))
+	}
+	if !query.LastLoginAfter.IsZero() {
+		db = db.Where("last_login >= ?", query.LastLoginAfter)
+	}
+	if !query.LastLoginBefore.IsZero() {
+		db = db.Where("last_login < ?", query.LastLoginBefore)
+	}
+	if query.Disabled != nil && *query.Disabled {
+		db = db.Where("disabled_at IS NOT NULL")
+	}
+	if query.Disabled != nil && !*query.Disabled {
+		db = db.Where("disabled_at IS NULL")
+	}
+
+	return db
 }
 
 func GetUserByEmail(email string) (*models.User, error) {
@@ -83,22 +155,26 @@ func GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func GetUserByID(userID string) (*dtos.UserDto, error) {
+// GetUserByID returns a user, users without users:read can only get themselves
+func GetUserByID(context *gin.Context, userID string) (*dtos.UserDto, *interfaces.ServiceError) {
 
-	var user models.User
+	principal, err := GetPrincipal(context)
 
-	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
-
-		return nil, err
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
 	}
 
-	var userDto dtos.UserDto
-	userDto.ID = user.ID.String()
-	userDto.Email = user.Email
-	userDto.FirstName = user.FirstName
-	userDto.LastName = user.LastName
+	if principal.UserID.String() != userID && !principal.HasScope(models.PermissionUsersRead) {
+		return nil, &interfaces.ServiceError{
+			Error:      errors.New("Missing permission: " + models.PermissionUsersRead),
+			StatusCode: 403,
+		}
+	}
 
-	return &userDto, nil
+	return userDtoByID(userID)
 }
 
 func UpdateUser(context *gin.Context, userID string, updateUserDto *dtos.UpdateUserDto) (*models.User, *interfaces.ServiceError) {
@@ -128,18 +204,167 @@ func UpdateUser(context *gin.Context, userID string, updateUserDto *dtos.UpdateU
 		return nil, unauthorized
 	}
 
-	config.DB.Model(&user).Updates(models.User{
-		FirstName: updateUserDto.FirstName,
-		LastName:  updateUserDto.LastName,
-		Email:     updateUserDto.Email,
+	emailChanged := user.Email != updateUserDto.Email
+
+	// Check if Another User already uses the new email, addresses are compared case-insensitively
+	if emailChanged {
+		var userExists models.User
+
+		if err := config.DB.First(&userExists, "lower(email) = ? AND id <> ?", normalizeEmail(updateUserDto.Email), user.ID).Error; err == nil {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("User with email: " + updateUserDto.Email + " already exists"),
+				StatusCode: 409,
+			}
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(models.User{
+			FirstName: updateUserDto.FirstName,
+			LastName:  updateUserDto.LastName,
+			Email:     updateUserDto.Email,
+		}).Error
+
+		if err != nil {
+			return err
+		}
+
+		// a new email address has to be verified again
+		if emailChanged {
+			return tx.Model(&user).Update("email_verified_at", nil).Error
+		}
+
+		return nil
 	})
 
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, &interfaces.ServiceError{
+				Error:      errors.New("User with email: " + updateUserDto.Email + " already exists"),
+				StatusCode: 409,
+			}
+		}
+
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	if emailChanged {
+		SendVerificationEmail(&user)
+	}
+
 	user.Password = ""
 
 	return &user, nil
 
 }
 
+// SetUserDisabled disables or enables an account. A disabled account cannot log in and all of its
+// tokens stop working right away
+func SetUserDisabled(context *gin.Context, userID string, disabled bool) (*dtos.UserDto, *interfaces.ServiceError) {
+
+	var user models.User
+
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	principal, err := GetPrincipal(context)
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 401,
+		}
+	}
+
+	if disabled && principal.UserID == user.ID {
+		return nil, &interfaces.ServiceError{
+			Error:      errDisableOwnAccount,
+			StatusCode: 409,
+		}
+	}
+
+	if (user.DisabledAt != nil) == disabled {
+		return userDtoByID(userID)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if !disabled {
+			return tx.Model(&user).Update("disabled_at", nil).Error
+		}
+
+		if err := tx.Model(&user).Update("disabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := revokeRefreshTokens(tx.Where("user_id = ?", user.ID)); err != nil {
+			return err
+		}
+
+		return InvalidateUserTokens(tx, user.ID)
+	})
+
+	if err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	forgetTokenVersions(user.ID)
+
+	event := models.AuditUserEnabled
+
+	if disabled {
+		event = models.AuditUserDisabled
+	}
+
+	recordAudit(&models.AuditLog{
+		Event:   event,
+		UserID:  &user.ID,
+		ActorID: &principal.UserID,
+		Email:   normalizeEmail(user.Email),
+	})
+
+	return userDtoByID(userID)
+}
+
+// CheckAccountActive fails for disabled accounts, which must not get tokens
+func CheckAccountActive(user *models.User) *interfaces.ServiceError {
+
+	if user.DisabledAt != nil {
+		return &interfaces.ServiceError{
+			Error:      errUserDisabled,
+			StatusCode: 403,
+		}
+	}
+
+	return nil
+}
+
+// CheckPasswordLogin fails for accounts that are disabled or have to reset their password before logging in with it
+func CheckPasswordLogin(user *models.User) *interfaces.ServiceError {
+
+	if serviceError := CheckAccountActive(user); serviceError != nil {
+		return serviceError
+	}
+
+	if user.PasswordResetRequired {
+		return &interfaces.ServiceError{
+			Error:      errPasswordResetRequired,
+			StatusCode: 403,
+		}
+	}
+
+	return nil
+}
+
 func DeleteUser(userID string) error {
 
 	result := config.DB.Delete(&models.User{}, "id = ?", userID)
@@ -148,6 +373,35 @@ func DeleteUser(userID string) error {
 		return result.Error
 	}
 
+	tokenVersionCache.Delete(userID)
+
 	return nil
 
 }
+
+// userDtoByID returns a user for the responses of admin actions on it
+func userDtoByID(userID string) (*dtos.UserDto, *interfaces.ServiceError) {
+
+	var user models.User
+
+	if err := config.DB.Preload("Roles").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 404,
+		}
+	}
+
+	return userDto(&user), nil
+}
+
+func userDto(user *models.User) *dtos.UserDto {
+	return &dtos.UserDto{
+		ID:         user.ID.String(),
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Roles:      roleNames(user.Roles),
+		LastLogin:  user.LastLogin,
+		DisabledAt: user.DisabledAt,
+	}
+}
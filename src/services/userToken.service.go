@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// a new token is only mailed when the last one of the same purpose is older than this
+const userTokenResendInterval = time.Minute
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// createUserToken returns a new single use token for the purpose, earlier unused tokens
+// of the user for the same purpose stop working
+func createUserToken(tx *gorm.DB, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Omit("User").Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// findUserToken locks and returns a token without using it up, it fails when the token is unknown,
+// meant for another purpose, expired or already used
+func findUserToken(tx *gorm.DB, token string, purpose string) (*models.UserToken, error) {
+	var userToken models.UserToken
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&userToken, "token_hash = ? AND purpose = ?", hashToken(token), purpose).Error
+
+	if err != nil {
+		return nil, errInvalidUserToken
+	}
+
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	return &userToken, nil
+}
+
+// consumeUserToken marks a token as used and returns it, failing like findUserToken
+func consumeUserToken(tx *gorm.DB, token string, purpose string) (*models.UserToken, error) {
+	userToken, err := findUserToken(tx, token, purpose)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userToken.UsedAt = &now
+
+	if err := tx.Model(userToken).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	return userToken, nil
+}
+
+// userTokenCreatedWithin reports whether the user got a token for the purpose in the last interval,
+// used to stop mail endpoints from flooding an inbox
+func userTokenCreatedWithin(userID uuid.UUID, purpose string, interval time.Duration) bool {
+	var lastToken models.UserToken
+
+	err := config.DB.Order("created_at DESC").
+		First(&lastToken, "user_id = ? AND purpose = ?", userID, purpose).Error
+
+	return err == nil && time.Since(lastToken.CreatedAt) < interval
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jaimy-monsuur/movie-api/src/config"
+	"github.com/jaimy-monsuur/movie-api/src/interfaces"
+	"github.com/jaimy-monsuur/movie-api/src/mailer"
+	"github.com/jaimy-monsuur/movie-api/src/models"
+	"gorm.io/gorm"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// SendVerificationEmail mails a verification link to the user, errors are only logged
+// so a mail server outage does not fail the registration
+func SendVerificationEmail(user *models.User) {
+
+	if userTokenCreatedWithin(user.ID, models.UserTokenEmailVerification, userTokenResendInterval) {
+		return
+	}
+
+	token, err := createUserToken(config.DB, user.ID, models.UserTokenEmailVerification, emailVerificationTTL)
+
+	if err != nil {
+		log.Printf("Failed to create email verification token for user %s: %v", user.ID, err)
+		return
+	}
+
+	err = config.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Please verify your email address by opening the link below, it expires in 24 hours.\n\n" +
+			appLink("/verify-email", token) + "\n",
+	})
+
+	if err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+}
+
+// ResendVerificationEmail sends a new verification email when an unverified user with the email exists,
+// callers always get the same answer so the endpoint cannot be used to find registered emails
+func ResendVerificationEmail(email string) {
+
+	user, err := GetUserByEmail(email)
+
+	if err != nil || user.EmailVerifiedAt != nil {
+		return
+	}
+
+	SendVerificationEmail(user)
+}
+
+func VerifyEmail(token string) *interfaces.ServiceError {
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.UserTokenEmailVerification)
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+
+	if err == errInvalidUserToken {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 400,
+		}
+	}
+
+	if err != nil {
+		return &interfaces.ServiceError{
+			Error:      err,
+			StatusCode: 500,
+		}
+	}
+
+	return nil
+}
+
+// appLink returns a link to a page of the front end at APP_URL carrying a token
+func appLink(path string, token string) string {
+	return os.Getenv("APP_URL") + path + "?token=" + url.QueryEscape(token)
+}
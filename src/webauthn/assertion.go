@@ -0,0 +1,127 @@
+package webauthn
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ErrSignCountRegression means the authenticator reported a sign counter that did not increase,
+// which happens when a credential was cloned
+var ErrSignCountRegression = fmt.Errorf("%w: sign counter did not increase, the credential may be cloned", ErrVerification)
+
+// RequestOptions are handed to navigator.credentials.get() to log in with a passkey
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AssertionResponse is the JSON serialization of the credential navigator.credentials.get() returns
+type AssertionResponse struct {
+	ID       string                         `json:"id" binding:"required"`
+	RawID    string                         `json:"rawId" binding:"required"`
+	Type     string                         `json:"type" binding:"required,eq=public-key"`
+	Response AuthenticatorAssertionResponse `json:"response" binding:"required"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// Assertion is the verified outcome of a login, the new sign count has to be stored with the credential
+type Assertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+// RequestOptions returns the options to log in with a passkey, without allowed credentials the browser
+// offers every passkey it has for the relying party
+func (relyingParty *RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          ceremonyTimeout,
+		RPID:             relyingParty.ID,
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}
+}
+
+// CredentialID returns the raw id of the credential a response was made with
+func (response *AssertionResponse) CredentialID() ([]byte, error) {
+	rawID, err := decodeBase64URL(response.RawID)
+
+	if err != nil || len(rawID) == 0 {
+		return nil, fmt.Errorf("%w: invalid credential id", ErrVerification)
+	}
+
+	return rawID, nil
+}
+
+// VerifyAssertion verifies the response to RequestOptions with the given challenge against the stored public key
+// and sign count of the credential. The user handle, when the authenticator returns one, must match the stored one
+func (relyingParty *RelyingParty) VerifyAssertion(response *AssertionResponse, challenge string, coseKey []byte, userHandle []byte, storedSignCount uint32) (*Assertion, error) {
+	clientDataHash, err := relyingParty.verifyClientData(response.Response.ClientDataJSON, ceremonyGet, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := decodeBase64URL(response.Response.AuthenticatorData)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid authenticator data encoding", ErrVerification)
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authData.verify(relyingParty); err != nil {
+		return nil, err
+	}
+
+	if response.Response.UserHandle != "" {
+		returnedHandle, err := decodeBase64URL(response.Response.UserHandle)
+
+		if err != nil || !bytes.Equal(returnedHandle, userHandle) {
+			return nil, fmt.Errorf("%w: user handle does not match the credential", ErrVerification)
+		}
+	}
+
+	signature, err := decodeBase64URL(response.Response.Signature)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrVerification)
+	}
+
+	credentialKey, err := parsePublicKey(coseKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := credentialKey.verify(append(rawAuthData, clientDataHash...), signature); err != nil {
+		return nil, err
+	}
+
+	// authenticators without a counter always report zero, synced passkeys among them
+	if (authData.signCount != 0 || storedSignCount != 0) && authData.signCount <= storedSignCount {
+		return nil, ErrSignCountRegression
+	}
+
+	return &Assertion{
+		SignCount: authData.signCount,
+		BackedUp:  authData.flags&flagBackedUp != 0,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagBackupEligible         = 0x08
+	flagBackedUp               = 0x10
+	flagAttestedCredentialData = 0x40
+
+	// rpIdHash (32) + flags (1) + signCount (4)
+	authenticatorDataMinLength = 37
+)
+
+// authenticatorData is what the authenticator signs over together with the client data hash
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// only set for registrations
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < authenticatorDataMinLength {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrVerification)
+	}
+
+	data := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if data.flags&flagAttestedCredentialData == 0 {
+		return data, nil
+	}
+
+	rest := raw[authenticatorDataMinLength:]
+
+	// aaguid (16) + credentialIdLength (2)
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data is too short", ErrVerification)
+	}
+
+	data.aaguid = rest[:16]
+	credentialIDLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+
+	if credentialIDLength == 0 || credentialIDLength > 1023 || len(rest) < credentialIDLength {
+		return nil, fmt.Errorf("%w: invalid credential id length", ErrVerification)
+	}
+
+	data.credentialID = rest[:credentialIDLength]
+	rest = rest[credentialIDLength:]
+
+	// the public key is a COSE key of unknown length, extensions may follow it
+	decoder := cbor.NewDecoder(bytes.NewReader(rest))
+
+	var publicKey cbor.RawMessage
+
+	if err := decoder.Decode(&publicKey); err != nil {
+		return nil, fmt.Errorf("%w: invalid credential public key", ErrVerification)
+	}
+
+	data.publicKey = rest[:decoder.NumBytesRead()]
+
+	return data, nil
+}
+
+// verify checks that the data is meant for the relying party and that the user was present and verified
+func (data *authenticatorData) verify(relyingParty *RelyingParty) error {
+	rpIDHash := sha256.Sum256([]byte(relyingParty.ID))
+
+	if subtle.ConstantTimeCompare(data.rpIDHash, rpIDHash[:]) != 1 {
+		return fmt.Errorf("%w: credential belongs to another relying party", ErrVerification)
+	}
+
+	if data.flags&flagUserPresent == 0 {
+		return fmt.Errorf("%w: user was not present", ErrVerification)
+	}
+
+	// passkeys replace the password and the second factor, so the authenticator has to verify the user
+	if data.flags&flagUserVerified == 0 {
+		return fmt.Errorf("%w: user was not verified", ErrVerification)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE algorithm identifiers of the supported credentials, in order of preference
+const (
+	AlgorithmES256 = -7
+	AlgorithmEdDSA = -8
+	AlgorithmRS256 = -257
+)
+
+// COSE key parameters, https://www.iana.org/assignments/cose/cose.xhtml
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseKeyCurve     = -1
+	coseKeyX         = -2
+	coseKeyY         = -3
+	coseKeyRSAN      = -1
+	coseKeyRSAE      = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+var supportedAlgorithms = []int{AlgorithmES256, AlgorithmEdDSA, AlgorithmRS256}
+
+// publicKey is a decoded COSE key able to check signatures of its algorithm
+type publicKey struct {
+	algorithm int
+	key       crypto.PublicKey
+}
+
+func parsePublicKey(coseKey []byte) (*publicKey, error) {
+	var parameters map[int]interface{}
+
+	if err := cbor.Unmarshal(coseKey, &parameters); err != nil {
+		return nil, fmt.Errorf("%w: invalid COSE key", ErrVerification)
+	}
+
+	keyType, _ := coseInt(parameters[coseKeyType])
+	algorithm, _ := coseInt(parameters[coseKeyAlgorithm])
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == AlgorithmES256:
+		curve, _ := coseInt(parameters[coseKeyCurve])
+		x, _ := parameters[coseKeyX].([]byte)
+		y, _ := parameters[coseKeyY].([]byte)
+
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: invalid ES256 key", ErrVerification)
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("%w: ES256 key is not on the curve", ErrVerification)
+		}
+
+		return &publicKey{algorithm: algorithm, key: key}, nil
+	case keyType == coseKeyTypeOKP && algorithm == AlgorithmEdDSA:
+		curve, _ := coseInt(parameters[coseKeyCurve])
+		x, _ := parameters[coseKeyX].([]byte)
+
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid EdDSA key", ErrVerification)
+		}
+
+		return &publicKey{algorithm: algorithm, key: ed25519.PublicKey(x)}, nil
+	case keyType == coseKeyTypeRSA && algorithm == AlgorithmRS256:
+		n, _ := parameters[coseKeyRSAN].([]byte)
+		e, _ := parameters[coseKeyRSAE].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: invalid RS256 key", ErrVerification)
+		}
+
+		return &publicKey{algorithm: algorithm, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrVerification, keyType, algorithm)
+	}
+}
+
+// verify checks a signature over data, which is hashed as the algorithm requires
+func (key *publicKey) verify(data []byte, signature []byte) error {
+	valid := false
+
+	switch publicKey := key.key.(type) {
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(publicKey, hash[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, data, signature)
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) == nil
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: invalid signature", ErrVerification)
+	}
+
+	return nil
+}
+
+// coseInt reads an integer parameter, CBOR decodes positive integers as uint64 and negative ones as int64
+func coseInt(value interface{}) (int, bool) {
+	switch value := value.(type) {
+	case uint64:
+		return int(value), true
+	case int64:
+		return int(value), true
+	}
+
+	return 0, false
+}
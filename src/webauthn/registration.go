@@ -0,0 +1,232 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CreationOptions are handed to navigator.credentials.create() to register a passkey
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON serialization of the credential navigator.credentials.create() returns
+type RegistrationResponse struct {
+	ID       string                           `json:"id" binding:"required"`
+	RawID    string                           `json:"rawId" binding:"required"`
+	Type     string                           `json:"type" binding:"required,eq=public-key"`
+	Response AuthenticatorAttestationResponse `json:"response" binding:"required"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+// Credential is a verified new credential, to be stored with the user that registered it
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE encoded public key, as the authenticator sent it
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+type attestationObject struct {
+	Format    string          `cbor:"fmt"`
+	Statement cbor.RawMessage `cbor:"attStmt"`
+	AuthData  []byte          `cbor:"authData"`
+}
+
+type packedStatement struct {
+	Algorithm    int      `cbor:"alg"`
+	Signature    []byte   `cbor:"sig"`
+	Certificates [][]byte `cbor:"x5c"`
+}
+
+// CreationOptions returns the options to register a passkey for a user, existing passkeys of the user are excluded
+// so an authenticator is not registered twice. The user handle is stored in the passkey and returned when logging in
+func (relyingParty *RelyingParty) CreationOptions(challenge string, userHandle []byte, name string, displayName string, exclude []CredentialDescriptor) *CreationOptions {
+	parameters := make([]CredentialParameter, 0, len(supportedAlgorithms))
+
+	for _, algorithm := range supportedAlgorithms {
+		parameters = append(parameters, CredentialParameter{Type: publicKeyCredentialType, Alg: algorithm})
+	}
+
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: relyingParty.ID, Name: relyingParty.Name},
+		User: UserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(userHandle),
+			Name:        name,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams:   parameters,
+		Timeout:            ceremonyTimeout,
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// VerifyRegistration verifies the response to CreationOptions with the given challenge and returns the new credential
+func (relyingParty *RelyingParty) VerifyRegistration(response *RegistrationResponse, challenge string) (*Credential, error) {
+	clientDataHash, err := relyingParty.verifyClientData(response.Response.ClientDataJSON, ceremonyCreate, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawObject, err := decodeBase64URL(response.Response.AttestationObject)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object encoding", ErrVerification)
+	}
+
+	var object attestationObject
+
+	if err := cbor.Unmarshal(rawObject, &object); err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object", ErrVerification)
+	}
+
+	authData, err := parseAuthenticatorData(object.AuthData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authData.verify(relyingParty); err != nil {
+		return nil, err
+	}
+
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrVerification)
+	}
+
+	rawID, err := decodeBase64URL(response.RawID)
+
+	if err != nil || !bytes.Equal(rawID, authData.credentialID) {
+		return nil, fmt.Errorf("%w: credential id does not match the attested credential", ErrVerification)
+	}
+
+	credentialKey, err := parsePublicKey(authData.publicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyAttestation(&object, credentialKey, clientDataHash); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		Transports:     response.Response.Transports,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		BackedUp:       authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// verifyAttestation checks the attestation statement is intact. Attestation certificates are not checked against
+// a trust store, the API does not restrict which authenticators can be used
+func verifyAttestation(object *attestationObject, credentialKey *publicKey, clientDataHash []byte) error {
+	switch object.Format {
+	case "none":
+		var statement map[string]interface{}
+
+		if err := cbor.Unmarshal(object.Statement, &statement); err != nil || len(statement) != 0 {
+			return fmt.Errorf("%w: none attestation must have an empty statement", ErrVerification)
+		}
+
+		return nil
+	case "packed":
+		var statement packedStatement
+
+		if err := cbor.Unmarshal(object.Statement, &statement); err != nil {
+			return fmt.Errorf("%w: invalid packed attestation statement", ErrVerification)
+		}
+
+		signedData := append(append([]byte{}, object.AuthData...), clientDataHash...)
+
+		if len(statement.Certificates) == 0 {
+			// self attestation, signed with the credential key itself
+			if statement.Algorithm != credentialKey.algorithm {
+				return fmt.Errorf("%w: self attestation algorithm does not match the credential", ErrVerification)
+			}
+
+			return credentialKey.verify(signedData, statement.Signature)
+		}
+
+		certificate, err := x509.ParseCertificate(statement.Certificates[0])
+
+		if err != nil {
+			return fmt.Errorf("%w: invalid attestation certificate", ErrVerification)
+		}
+
+		signatureAlgorithms := map[int]x509.SignatureAlgorithm{
+			AlgorithmES256: x509.ECDSAWithSHA256,
+			AlgorithmEdDSA: x509.PureEd25519,
+			AlgorithmRS256: x509.SHA256WithRSA,
+		}
+
+		algorithm, ok := signatureAlgorithms[statement.Algorithm]
+
+		if !ok {
+			return fmt.Errorf("%w: unsupported attestation algorithm %d", ErrVerification, statement.Algorithm)
+		}
+
+		if err := certificate.CheckSignature(algorithm, signedData, statement.Signature); err != nil {
+			return fmt.Errorf("%w: invalid attestation signature", ErrVerification)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported attestation format %q", ErrVerification, object.Format)
+	}
+}
@@ -0,0 +1,196 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// SoftwareAuthenticator is an in memory ES256 authenticator that answers ceremonies the way a browser with a
+// platform authenticator would, so the passkey flows can be exercised in tests and scripts without a browser
+type SoftwareAuthenticator struct {
+	Origin      string
+	credentials map[string]*softwareCredential
+}
+
+type softwareCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// NewSoftwareAuthenticator returns an authenticator without credentials that claims to run on the given origin
+func NewSoftwareAuthenticator(origin string) *SoftwareAuthenticator {
+	return &SoftwareAuthenticator{Origin: origin, credentials: map[string]*softwareCredential{}}
+}
+
+// Register creates a credential for the options and returns the response the browser would send
+func (authenticator *SoftwareAuthenticator) Register(options *CreationOptions) (*RegistrationResponse, error) {
+	for _, excluded := range options.ExcludeCredentials {
+		if credential, ok := authenticator.credentials[excluded.ID]; ok && credential.rpID == options.RP.ID {
+			return nil, errors.New("authenticator already holds an excluded credential")
+		}
+	}
+
+	userHandle, err := decodeBase64URL(options.User.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &softwareCredential{id: make([]byte, 16), rpID: options.RP.ID, userHandle: userHandle, key: key}
+
+	if _, err := rand.Read(credential.id); err != nil {
+		return nil, err
+	}
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		coseKeyType:      coseKeyTypeEC2,
+		coseKeyAlgorithm: AlgorithmES256,
+		coseKeyCurve:     coseCurveP256,
+		coseKeyX:         key.X.FillBytes(make([]byte, 32)),
+		coseKeyY:         key.Y.FillBytes(make([]byte, 32)),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// an all zero aaguid, as authenticators without attestation report
+	attestedCredentialData := make([]byte, 18, 18+len(credential.id)+len(coseKey))
+	binary.BigEndian.PutUint16(attestedCredentialData[16:], uint16(len(credential.id)))
+	attestedCredentialData = append(append(attestedCredentialData, credential.id...), coseKey...)
+
+	authData := credential.authenticatorData(flagAttestedCredentialData, attestedCredentialData)
+
+	object, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := authenticator.clientData(ceremonyCreate, options.Challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(credential.id)
+	authenticator.credentials[id] = credential
+
+	return &RegistrationResponse{
+		ID:    id,
+		RawID: id,
+		Type:  publicKeyCredentialType,
+		Response: AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: base64.RawURLEncoding.EncodeToString(object),
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Assert signs the challenge of the options with a matching credential and returns the response the browser would send
+func (authenticator *SoftwareAuthenticator) Assert(options *RequestOptions) (*AssertionResponse, error) {
+	credential := authenticator.findCredential(options)
+
+	if credential == nil {
+		return nil, errors.New("authenticator holds no credential for these options")
+	}
+
+	credential.signCount++
+
+	authData := credential.authenticatorData(0, nil)
+
+	clientDataJSON, err := authenticator.clientData(ceremonyGet, options.Challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawClientData, _ := decodeBase64URL(clientDataJSON)
+	clientDataHash := sha256.Sum256(rawClientData)
+	hash := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.key, hash[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(credential.id)
+
+	return &AssertionResponse{
+		ID:    id,
+		RawID: id,
+		Type:  publicKeyCredentialType,
+		Response: AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+			Signature:         base64.RawURLEncoding.EncodeToString(signature),
+			UserHandle:        base64.RawURLEncoding.EncodeToString(credential.userHandle),
+		},
+	}, nil
+}
+
+// findCredential picks the first allowed credential, or any credential of the relying party when none are listed
+func (authenticator *SoftwareAuthenticator) findCredential(options *RequestOptions) *softwareCredential {
+	if len(options.AllowCredentials) == 0 {
+		for _, credential := range authenticator.credentials {
+			if credential.rpID == options.RPID {
+				return credential
+			}
+		}
+
+		return nil
+	}
+
+	for _, allowed := range options.AllowCredentials {
+		if credential, ok := authenticator.credentials[allowed.ID]; ok && credential.rpID == options.RPID {
+			return credential
+		}
+	}
+
+	return nil
+}
+
+func (authenticator *SoftwareAuthenticator) clientData(ceremony string, challenge string) (string, error) {
+	raw, err := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: authenticator.Origin})
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// authenticatorData reports a present and verified user, as if the user unlocked the device
+func (credential *softwareCredential) authenticatorData(flags byte, attestedCredentialData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(credential.rpID))
+
+	data := make([]byte, authenticatorDataMinLength, authenticatorDataMinLength+len(attestedCredentialData))
+	copy(data, rpIDHash[:])
+	data[32] = flags | flagUserPresent | flagUserVerified
+	binary.BigEndian.PutUint32(data[33:], credential.signCount)
+
+	return append(data, attestedCredentialData...)
+}
@@ -0,0 +1,161 @@
+// Package webauthn implements the relying party side of the WebAuthn registration and authentication
+// ceremonies for passkeys. It supports ES256, EdDSA and RS256 credentials with "none" and "packed" attestation
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	publicKeyCredentialType = "public-key"
+
+	// ceremonies time out in the browser after this many milliseconds
+	ceremonyTimeout = 5 * 60 * 1000
+)
+
+// ErrVerification is wrapped by every error about a response that does not check out
+var ErrVerification = errors.New("webauthn verification failed")
+
+// RelyingParty is this API as WebAuthn sees it, configured with WEBAUTHN_* environment variables
+type RelyingParty struct {
+	// ID is the domain credentials are scoped to, e.g. "movies.example.com"
+	ID   string
+	Name string
+	// Origins are the web origins ceremonies may run on, e.g. "https://movies.example.com"
+	Origins []string
+}
+
+// NewFromEnv returns the relying party configured by WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and WEBAUTHN_ORIGINS,
+// the ID and origin default to the host and origin of APP_URL
+func NewFromEnv() (*RelyingParty, error) {
+	relyingParty := &RelyingParty{
+		ID:      os.Getenv("WEBAUTHN_RP_ID"),
+		Name:    os.Getenv("WEBAUTHN_RP_NAME"),
+		Origins: strings.Fields(os.Getenv("WEBAUTHN_ORIGINS")),
+	}
+
+	if relyingParty.Name == "" {
+		relyingParty.Name = "Movie API"
+	}
+
+	if relyingParty.ID == "" || len(relyingParty.Origins) == 0 {
+		appURL, err := url.Parse(os.Getenv("APP_URL"))
+
+		if err != nil || appURL.Host == "" {
+			return nil, errors.New("WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS are required when APP_URL is not set")
+		}
+
+		if relyingParty.ID == "" {
+			relyingParty.ID = appURL.Hostname()
+		}
+
+		if len(relyingParty.Origins) == 0 {
+			relyingParty.Origins = []string{appURL.Scheme + "://" + appURL.Host}
+		}
+	}
+
+	return relyingParty, nil
+}
+
+// CredentialDescriptor points at a credential the authenticator should exclude or use
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// NewCredentialDescriptor describes a stored credential, transports are hints for the browser
+func NewCredentialDescriptor(credentialID []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{
+		Type:       publicKeyCredentialType,
+		ID:         base64.RawURLEncoding.EncodeToString(credentialID),
+		Transports: transports,
+	}
+}
+
+// clientData is the JSON the browser signs over, it binds a response to the challenge and origin
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ChallengeOf returns the challenge a response answers without verifying anything, so the caller
+// can look up the ceremony it belongs to before verifying the response against it
+func ChallengeOf(encodedClientData string) (string, error) {
+	data, _, err := decodeClientData(encodedClientData)
+
+	if err != nil {
+		return "", err
+	}
+
+	return data.Challenge, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin, returning the SHA-256 hash of the client data
+func (relyingParty *RelyingParty) verifyClientData(encodedClientData string, ceremony string, challenge string) ([]byte, error) {
+	data, raw, err := decodeClientData(encodedClientData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("%w: expected a %s ceremony, got %q", ErrVerification, ceremony, data.Type)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return nil, fmt.Errorf("%w: challenge does not match", ErrVerification)
+	}
+
+	if !relyingParty.allowsOrigin(data.Origin) || data.CrossOrigin {
+		return nil, fmt.Errorf("%w: origin %q is not allowed", ErrVerification, data.Origin)
+	}
+
+	hash := sha256.Sum256(raw)
+
+	return hash[:], nil
+}
+
+func (relyingParty *RelyingParty) allowsOrigin(origin string) bool {
+	for _, allowed := range relyingParty.Origins {
+		if origin == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeClientData(encodedClientData string) (*clientData, []byte, error) {
+	raw, err := decodeBase64URL(encodedClientData)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid client data encoding", ErrVerification)
+	}
+
+	var data clientData
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid client data", ErrVerification)
+	}
+
+	return &data, raw, nil
+}
+
+// decodeBase64URL accepts base64url with and without padding, browsers and libraries differ
+func decodeBase64URL(encoded string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
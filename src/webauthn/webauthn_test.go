@@ -0,0 +1,189 @@
+package webauthn
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	testOrigin = "https://movies.example.com"
+	testRPID   = "movies.example.com"
+)
+
+var testUserHandle = []byte("0123456789abcdef")
+
+func testRelyingParty() *RelyingParty {
+	return &RelyingParty{ID: testRPID, Name: "Movie API", Origins: []string{testOrigin}}
+}
+
+// register runs a registration ceremony of the authenticator with the relying party
+func register(t *testing.T, relyingParty *RelyingParty, authenticator *SoftwareAuthenticator) *Credential {
+	t.Helper()
+
+	options := relyingParty.CreationOptions("registration-challenge", testUserHandle, "jane@example.com", "Jane", nil)
+
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	credential, err := relyingParty.VerifyRegistration(response, "registration-challenge")
+	if err != nil {
+		t.Fatalf("verify registration: %v", err)
+	}
+
+	return credential
+}
+
+func assert(t *testing.T, relyingParty *RelyingParty, authenticator *SoftwareAuthenticator, credential *Credential, challenge string) *AssertionResponse {
+	t.Helper()
+
+	options := relyingParty.RequestOptions(challenge, []CredentialDescriptor{NewCredentialDescriptor(credential.ID, credential.Transports)})
+
+	response, err := authenticator.Assert(options)
+	if err != nil {
+		t.Fatalf("assert: %v", err)
+	}
+
+	return response
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	relyingParty := testRelyingParty()
+	authenticator := NewSoftwareAuthenticator(testOrigin)
+
+	credential := register(t, relyingParty, authenticator)
+
+	if len(credential.ID) == 0 || len(credential.PublicKey) == 0 {
+		t.Fatalf("credential without id or public key: %+v", credential)
+	}
+
+	if credential.SignCount != 0 {
+		t.Errorf("sign count after registration = %d, want 0", credential.SignCount)
+	}
+
+	signCount := credential.SignCount
+
+	for i := 1; i <= 3; i++ {
+		response := assert(t, relyingParty, authenticator, credential, "login-challenge")
+
+		rawID, err := response.CredentialID()
+		if err != nil || string(rawID) != string(credential.ID) {
+			t.Fatalf("credential id of the assertion = %x, %v, want %x", rawID, err, credential.ID)
+		}
+
+		assertion, err := relyingParty.VerifyAssertion(response, "login-challenge", credential.PublicKey, testUserHandle, signCount)
+		if err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+
+		if assertion.SignCount != uint32(i) {
+			t.Errorf("login %d: sign count = %d, want %d", i, assertion.SignCount, i)
+		}
+
+		signCount = assertion.SignCount
+	}
+}
+
+func TestAssertionSignCountRegression(t *testing.T) {
+	relyingParty := testRelyingParty()
+	authenticator := NewSoftwareAuthenticator(testOrigin)
+	credential := register(t, relyingParty, authenticator)
+
+	response := assert(t, relyingParty, authenticator, credential, "login-challenge")
+
+	assertion, err := relyingParty.VerifyAssertion(response, "login-challenge", credential.PublicKey, testUserHandle, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a replayed response, or a clone of the credential, reports a counter that did not increase
+	for _, storedSignCount := range []uint32{assertion.SignCount, assertion.SignCount + 5} {
+		_, err := relyingParty.VerifyAssertion(response, "login-challenge", credential.PublicKey, testUserHandle, storedSignCount)
+
+		if !errors.Is(err, ErrSignCountRegression) {
+			t.Errorf("stored sign count %d: err = %v, want ErrSignCountRegression", storedSignCount, err)
+		}
+	}
+}
+
+func TestRegistrationRejectsForeignOriginsAndRelyingParties(t *testing.T) {
+	relyingParty := testRelyingParty()
+
+	tests := []struct {
+		name   string
+		origin string
+		rpID   string
+	}{
+		{name: "other origin", origin: "https://evil.example.com", rpID: testRPID},
+		{name: "other relying party id", origin: testOrigin, rpID: "evil.example.com"},
+		{name: "http origin", origin: "http://movies.example.com", rpID: testRPID},
+	}
+
+	for _, test := range tests {
+		foreign := &RelyingParty{ID: test.rpID, Name: "Movie API", Origins: []string{test.origin}}
+		options := foreign.CreationOptions("registration-challenge", testUserHandle, "jane@example.com", "Jane", nil)
+
+		response, err := NewSoftwareAuthenticator(test.origin).Register(options)
+		if err != nil {
+			t.Fatalf("%s: register: %v", test.name, err)
+		}
+
+		if _, err := relyingParty.VerifyRegistration(response, "registration-challenge"); !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: err = %v, want ErrVerification", test.name, err)
+		}
+	}
+
+	// a response to another challenge is a replay
+	options := relyingParty.CreationOptions("registration-challenge", testUserHandle, "jane@example.com", "Jane", nil)
+
+	response, err := NewSoftwareAuthenticator(testOrigin).Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := relyingParty.VerifyRegistration(response, "other-challenge"); !errors.Is(err, ErrVerification) {
+		t.Errorf("other challenge: err = %v, want ErrVerification", err)
+	}
+}
+
+func TestAssertionRejectsMismatches(t *testing.T) {
+	relyingParty := testRelyingParty()
+	authenticator := NewSoftwareAuthenticator(testOrigin)
+	credential := register(t, relyingParty, authenticator)
+	otherCredential := register(t, relyingParty, NewSoftwareAuthenticator(testOrigin))
+
+	// the same credential id and key, but used from a phishing origin
+	phishing := &SoftwareAuthenticator{Origin: "https://movies.example.com.evil.test", credentials: authenticator.credentials}
+
+	tests := []struct {
+		name          string
+		authenticator *SoftwareAuthenticator
+		relyingParty  *RelyingParty
+		challenge     string
+		publicKey     []byte
+		userHandle    []byte
+	}{
+		{name: "other origin", authenticator: phishing, relyingParty: relyingParty, challenge: "login-challenge",
+			publicKey: credential.PublicKey, userHandle: testUserHandle},
+		{name: "other relying party id", authenticator: authenticator,
+			relyingParty: &RelyingParty{ID: "example.com", Name: "Movie API", Origins: []string{testOrigin}}, challenge: "login-challenge",
+			publicKey: credential.PublicKey, userHandle: testUserHandle},
+		{name: "other challenge", authenticator: authenticator, relyingParty: relyingParty, challenge: "other-challenge",
+			publicKey: credential.PublicKey, userHandle: testUserHandle},
+		{name: "other public key", authenticator: authenticator, relyingParty: relyingParty, challenge: "login-challenge",
+			publicKey: otherCredential.PublicKey, userHandle: testUserHandle},
+		{name: "other user handle", authenticator: authenticator, relyingParty: relyingParty, challenge: "login-challenge",
+			publicKey: credential.PublicKey, userHandle: []byte("fedcba9876543210")},
+	}
+
+	for _, test := range tests {
+		response := assert(t, relyingParty, test.authenticator, credential, "login-challenge")
+
+		_, err := test.relyingParty.VerifyAssertion(response, test.challenge, test.publicKey, test.userHandle, 0)
+
+		if !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: err = %v, want ErrVerification", test.name, err)
+		}
+	}
+}